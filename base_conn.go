@@ -2,9 +2,11 @@ package irc
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"net"
 	"sync"
+	"time"
 )
 
 // BaseConn is a simple IRC connection.
@@ -34,6 +36,21 @@ func BaseDial(addr string) (*BaseConn, error) {
 	return NewBaseConn(conn), nil
 }
 
+// BaseDialContext dials addr over TCP using the provided Dialer, and calls
+// NewBaseConn on the returned net.Conn. If d is nil, a zero net.Dialer is
+// used.
+func BaseDialContext(ctx context.Context, d Dialer, addr string) (*BaseConn, error) {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewBaseConn(conn), nil
+}
+
 // Close closes the underlying connection.
 func (b *BaseConn) Close() error {
 	return b.conn.Close()
@@ -56,5 +73,11 @@ func (b *BaseConn) Decode(m *Message) error {
 		}
 		return io.EOF
 	}
-	return m.Parse(b.scanner.Text())
+
+	if err := m.Parse(b.scanner.Text()); err != nil {
+		return err
+	}
+
+	m.Received = time.Now()
+	return nil
 }
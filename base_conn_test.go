@@ -4,6 +4,7 @@ import (
 	"io"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -59,6 +60,9 @@ func testBaseConnReceiver(t *testing.T, rConn *BaseConn, m *Message) {
 
 	got.Raw = "" // Don't check raw.
 
+	assert.False(t, got.Received.IsZero())
+	got.Received = time.Time{}
+
 	assert.Equal(t, m, &got)
 
 	err = rConn.Decode(&got)
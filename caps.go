@@ -0,0 +1,360 @@
+package irc
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxCapReqLen is the maximum length of the capability list in a CAP REQ,
+// leaving room for the rest of the message within 512 bytes.
+const maxCapReqLen = 400
+
+// Caps negotiates and tracks IRCv3 capabilities, using CAP LS 302. It is
+// safe for concurrent use.
+//
+// Set the fields, then pass the Caps to Register in RegisterOptions, which
+// negotiates capabilities during registration. After registration, CAP
+// messages (such as NEW and DEL from cap-notify) should be passed to Handle,
+// which irchandle.Caps does as a middleware.
+type Caps struct {
+	// Request lists the capabilities to request if the server supports
+	// them.
+	Request []string
+
+	// Fallbacks maps a capability in Request to alternatives, in order of
+	// preference, which are requested instead if the server does not support
+	// the capability or rejects it. For example, a draft version of a
+	// specification.
+	Fallbacks map[string][]string
+
+	// OnChange, if set, is called after capabilities are enabled or
+	// disabled once negotiation has finished, for example when the server
+	// adds or removes them using cap-notify.
+	OnChange func(enabled, disabled []string)
+
+	mu        sync.RWMutex
+	available map[string]string
+	enabled   map[string]string
+	lsDone    bool
+	pending   int
+	finished  bool
+
+	// alt records, for each capability which has been requested, which
+	// Request entry it is for and its index in that entry's alternatives.
+	alt map[string][2]int
+}
+
+// Enabled returns true if the capability is enabled.
+func (c *Caps) Enabled(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.enabled[name]
+	return ok
+}
+
+// Available returns the value advertised for a capability by the server
+// (for example, the mechanism list of "sasl"), and whether the server
+// supports it at all.
+func (c *Caps) Available(name string) (value string, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	value, ok = c.available[name]
+	return value, ok
+}
+
+// EnabledList returns the names of the enabled capabilities, sorted.
+func (c *Caps) EnabledList() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	list := make([]string, 0, len(c.enabled))
+	for name := range c.enabled {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// Reset clears all state, as is needed before negotiating on a new
+// connection. Register calls Reset.
+func (c *Caps) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.available = make(map[string]string)
+	c.enabled = make(map[string]string)
+	c.alt = make(map[string][2]int)
+	c.lsDone = false
+	c.pending = 0
+	c.finished = false
+}
+
+// settled returns true once the server's capabilities have been listed and
+// every request has been answered.
+func (c *Caps) settled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lsDone && c.pending == 0
+}
+
+// finish marks the end of negotiation; after this, changes are reported
+// using OnChange.
+func (c *Caps) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.finished = true
+}
+
+// Handle processes a CAP message, sending any requests which follow from it
+// to e. It returns false if the message is not a CAP message.
+func (c *Caps) Handle(e Encoder, m *Message) (handled bool, err error) {
+	if m.Command != "CAP" || len(m.Params) < 2 {
+		return false, nil
+	}
+
+	c.mu.Lock()
+
+	if c.available == nil {
+		c.available = make(map[string]string)
+		c.enabled = make(map[string]string)
+		c.alt = make(map[string][2]int)
+	}
+
+	var reqs [][]string
+	var enabled, disabled []string
+
+	// The capability list is the last parameter, which need not be the
+	// trailing parameter.
+	args := m.AllParams()[2:]
+
+	var list string
+	if len(args) > 0 {
+		list = args[len(args)-1]
+	}
+
+	switch strings.ToUpper(m.Params[1]) {
+	case "LS":
+		more := len(args) > 1 && args[0] == "*"
+		c.addAvailable(list)
+
+		if !more && !c.lsDone {
+			c.lsDone = true
+			reqs = c.initialRequests()
+		}
+
+	case "NEW":
+		names := c.addAvailable(list)
+		reqs = c.newRequests(names)
+
+	case "DEL":
+		for _, name := range strings.Fields(list) {
+			delete(c.available, name)
+			if _, ok := c.enabled[name]; ok {
+				delete(c.enabled, name)
+				disabled = append(disabled, name)
+			}
+		}
+
+	case "ACK":
+		c.answered()
+
+		for _, name := range strings.Fields(list) {
+			if name[0] == '-' {
+				name = name[1:]
+				delete(c.enabled, name)
+				disabled = append(disabled, name)
+				continue
+			}
+
+			c.enabled[name] = c.available[name]
+			enabled = append(enabled, name)
+		}
+
+	case "NAK":
+		c.answered()
+		reqs = c.retryRequests(strings.Fields(list))
+	}
+
+	c.pending += len(reqs)
+
+	onChange := c.OnChange
+	if !c.finished || (len(enabled) == 0 && len(disabled) == 0) {
+		onChange = nil
+	}
+
+	c.mu.Unlock()
+
+	for _, req := range reqs {
+		err := e.Encode(&Message{
+			Command:  "CAP",
+			Params:   []string{"REQ"},
+			Trailing: strings.Join(req, " "),
+		})
+		if err != nil {
+			return true, err
+		}
+	}
+
+	if onChange != nil {
+		onChange(enabled, disabled)
+	}
+
+	return true, nil
+}
+
+func (c *Caps) answered() {
+	if c.pending > 0 {
+		c.pending--
+	}
+}
+
+// addAvailable adds the capabilities in an LS or NEW list, returning their
+// names.
+func (c *Caps) addAvailable(list string) []string {
+	var names []string
+
+	for _, token := range strings.Fields(list) {
+		name, value := token, ""
+		if i := strings.IndexByte(token, '='); i != -1 {
+			name, value = token[:i], token[i+1:]
+		}
+
+		c.available[name] = value
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// initialRequests returns the requests to make once the server's
+// capabilities are known.
+func (c *Caps) initialRequests() [][]string {
+	var names []string
+
+	for i := range c.Request {
+		if name, ok := c.choose(i, 0); ok {
+			names = append(names, name)
+		}
+	}
+
+	return batchCaps(names)
+}
+
+// newRequests returns the requests to make when the server adds
+// capabilities.
+func (c *Caps) newRequests(added []string) [][]string {
+	isNew := make(map[string]bool, len(added))
+	for _, name := range added {
+		isNew[name] = true
+	}
+
+	var names []string
+
+	for i, want := range c.Request {
+		alts := c.alternatives(want)
+
+		// Skip entries which are already satisfied.
+		satisfied := false
+		for _, alt := range alts {
+			if _, ok := c.enabled[alt]; ok {
+				satisfied = true
+				break
+			}
+		}
+		if satisfied {
+			continue
+		}
+
+		for j, alt := range alts {
+			if isNew[alt] {
+				c.alt[alt] = [2]int{i, j}
+				names = append(names, alt)
+				break
+			}
+		}
+	}
+
+	return batchCaps(names)
+}
+
+// retryRequests handles a NAK. A rejected request for several capabilities
+// is retried one capability at a time, so that one unacceptable capability
+// does not prevent the others from being enabled. A rejected request for a
+// single capability moves on to its next fallback.
+func (c *Caps) retryRequests(rejected []string) [][]string {
+	if len(rejected) > 1 {
+		reqs := make([][]string, len(rejected))
+		for i, name := range rejected {
+			reqs[i] = []string{name}
+		}
+		return reqs
+	}
+
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	pos, ok := c.alt[rejected[0]]
+	if !ok {
+		return nil
+	}
+
+	if name, ok := c.choose(pos[0], pos[1]+1); ok {
+		return [][]string{{name}}
+	}
+
+	return nil
+}
+
+// choose returns the first available alternative for Request[i], starting
+// at index start, and records it as requested.
+func (c *Caps) choose(i, start int) (string, bool) {
+	alts := c.alternatives(c.Request[i])
+
+	for j := start; j < len(alts); j++ {
+		if _, ok := c.available[alts[j]]; ok {
+			c.alt[alts[j]] = [2]int{i, j}
+			return alts[j], true
+		}
+	}
+
+	return "", false
+}
+
+func (c *Caps) alternatives(name string) []string {
+	return append([]string{name}, c.Fallbacks[name]...)
+}
+
+// batchCaps splits a list of capabilities into requests which fit within a
+// single message.
+func batchCaps(names []string) [][]string {
+	var reqs [][]string
+	var cur []string
+	length := 0
+
+	for _, name := range names {
+		if len(cur) > 0 && length+1+len(name) > maxCapReqLen {
+			reqs = append(reqs, cur)
+			cur = nil
+			length = 0
+		}
+
+		if len(cur) > 0 {
+			length++
+		}
+		length += len(name)
+		cur = append(cur, name)
+	}
+
+	if len(cur) > 0 {
+		reqs = append(reqs, cur)
+	}
+
+	return reqs
+}
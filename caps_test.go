@@ -0,0 +1,144 @@
+package irc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterCaps(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org CAP * LS * :multi-prefix sasl=PLAIN,EXTERNAL draft/chathistory
+		S: :irc.example.org CAP * LS :server-time message-tags account-tag
+		C: CAP REQ :multi-prefix server-time draft/chathistory account-tag
+		S: :irc.example.org CAP * NAK :multi-prefix server-time draft/chathistory account-tag
+		C: CAP REQ :multi-prefix
+		C: CAP REQ :server-time
+		C: CAP REQ :draft/chathistory
+		C: CAP REQ :account-tag
+		S: :irc.example.org CAP * ACK multi-prefix
+		S: :irc.example.org CAP * ACK server-time
+		S: :irc.example.org CAP * NAK draft/chathistory
+		S: :irc.example.org CAP * ACK account-tag
+		C: CAP END
+		S: :irc.example.org 001 jake :Welcome
+		S: :irc.example.org 422 jake :MOTD File is missing
+	`)
+
+	caps := &Caps{
+		Request: []string{"multi-prefix", "server-time", "chathistory", "account-tag", "echo-message"},
+		Fallbacks: map[string][]string{
+			"chathistory": {"draft/chathistory"},
+		},
+	}
+
+	_, err := Register(context.Background(), conn, RegisterOptions{Nick: "jake", Caps: caps})
+	assert.NoError(t, err)
+	<-done
+
+	assert.Equal(t, []string{"account-tag", "multi-prefix", "server-time"}, caps.EnabledList())
+	assert.True(t, caps.Enabled("server-time"))
+	assert.False(t, caps.Enabled("draft/chathistory"))
+
+	v, ok := caps.Available("sasl")
+	assert.True(t, ok)
+	assert.Equal(t, "PLAIN,EXTERNAL", v)
+}
+
+func TestRegisterCapsUnsupported(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org 421 * CAP :Unknown command
+		S: :irc.example.org 001 jake :Welcome
+		S: :irc.example.org 376 jake :End of /MOTD command.
+	`)
+
+	caps := &Caps{Request: []string{"server-time"}}
+
+	w, err := Register(context.Background(), conn, RegisterOptions{Nick: "jake", Caps: caps})
+	assert.NoError(t, err)
+	<-done
+
+	assert.Equal(t, "jake", w.Nick)
+	assert.Empty(t, caps.EnabledList())
+}
+
+func TestCapsFallback(t *testing.T) {
+	r := &recordingEncoder{}
+	caps := &Caps{
+		Request: []string{"labeled-response"},
+		Fallbacks: map[string][]string{
+			"labeled-response": {"draft/labeled-response-0.2", "draft/labeled-response"},
+		},
+	}
+
+	handle := func(raw string) {
+		m, err := ParseMessage(raw)
+		assert.NoError(t, err)
+		handled, err := caps.Handle(r, m)
+		assert.True(t, handled)
+		assert.NoError(t, err)
+	}
+
+	handle("CAP * LS :labeled-response draft/labeled-response")
+	handle("CAP * NAK :labeled-response")
+	handle("CAP * ACK :draft/labeled-response")
+
+	var reqs []string
+	for _, m := range r.messages {
+		reqs = append(reqs, m.Trailing)
+	}
+
+	// draft/labeled-response-0.2 is skipped, as the server does not list it.
+	assert.Equal(t, []string{"labeled-response", "draft/labeled-response"}, reqs)
+	assert.True(t, caps.Enabled("draft/labeled-response"))
+}
+
+func TestCapsNotify(t *testing.T) {
+	r := &recordingEncoder{}
+
+	var changes [][2][]string
+	caps := &Caps{
+		Request: []string{"away-notify", "account-notify"},
+		OnChange: func(enabled, disabled []string) {
+			changes = append(changes, [2][]string{enabled, disabled})
+		},
+	}
+
+	handle := func(raw string) {
+		m, err := ParseMessage(raw)
+		assert.NoError(t, err)
+		_, err = caps.Handle(r, m)
+		assert.NoError(t, err)
+	}
+
+	handle("CAP * LS :away-notify")
+	handle("CAP * ACK :away-notify")
+	caps.finish()
+
+	handle("CAP jake NEW :account-notify extended-join")
+	handle("CAP jake ACK :account-notify")
+	handle("CAP jake DEL :away-notify")
+
+	assert.Len(t, r.messages, 2)
+	assert.Equal(t, "account-notify", r.messages[1].Trailing)
+
+	assert.Equal(t, []string{"account-notify"}, caps.EnabledList())
+	assert.Equal(t, [][2][]string{
+		{{"account-notify"}, nil},
+		{nil, {"away-notify"}},
+	}, changes)
+
+	_, ok := caps.Available("away-notify")
+	assert.False(t, ok)
+
+	handled, err := caps.Handle(r, &Message{Command: "PRIVMSG"})
+	assert.False(t, handled)
+	assert.NoError(t, err)
+}
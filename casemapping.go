@@ -0,0 +1,67 @@
+package irc
+
+import "strings"
+
+// CaseMapping is a server's method of comparing nicks and channel names
+// without regard to case, as advertised in the CASEMAPPING ISUPPORT token.
+type CaseMapping string
+
+// Casemappings in common use.
+const (
+	// CaseMappingASCII folds only the letters A to Z.
+	CaseMappingASCII CaseMapping = "ascii"
+
+	// CaseMappingRFC1459 also folds []\^ to {}|~, which were considered
+	// the uppercase forms of the latter in Scandinavian character sets.
+	// This is the default.
+	CaseMappingRFC1459 CaseMapping = "rfc1459"
+
+	// CaseMappingStrictRFC1459 is rfc1459, without folding ^ to ~.
+	CaseMappingStrictRFC1459 CaseMapping = "strict-rfc1459"
+)
+
+// Fold returns s in lowercase, according to the casemapping. Unknown
+// casemappings (such as rfc7613) fold ASCII letters only.
+func (c CaseMapping) Fold(s string) string {
+	var upper byte
+
+	switch c {
+	case CaseMappingRFC1459:
+		upper = '^'
+	case CaseMappingStrictRFC1459:
+		upper = ']'
+	default:
+		upper = 'Z'
+	}
+
+	// Avoid allocating when there is nothing to fold.
+	i := 0
+	for ; i < len(s); i++ {
+		if b := s[i]; b >= 'A' && b <= upper {
+			break
+		}
+	}
+
+	if i == len(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s))
+	sb.WriteString(s[:i])
+
+	for ; i < len(s); i++ {
+		b := s[i]
+		if b >= 'A' && b <= upper {
+			b += 'a' - 'A'
+		}
+		sb.WriteByte(b)
+	}
+
+	return sb.String()
+}
+
+// Equal returns true if a and b are equal under the casemapping.
+func (c CaseMapping) Equal(a, b string) bool {
+	return len(a) == len(b) && c.Fold(a) == c.Fold(b)
+}
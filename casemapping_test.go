@@ -0,0 +1,14 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaseMapping(t *testing.T) {
+	assert.Equal(t, "abc{}|~", CaseMappingRFC1459.Fold("ABC[]\\~"))
+	assert.Equal(t, "abc{}|^", CaseMappingStrictRFC1459.Fold("ABC[]\\^"))
+	assert.Equal(t, "abc[]", CaseMappingASCII.Fold("ABC[]"))
+	assert.True(t, CaseMappingRFC1459.Equal("Nick^", "nick~"))
+}
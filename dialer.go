@@ -0,0 +1,136 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// Dialer dials network connections. It is implemented by *net.Dialer, and
+// by the proxy and TLS dialers in this package, which can be composed to
+// (for example) run TLS through a SOCKS5 proxy:
+//
+//	d := &irc.TLSDialer{
+//		Dialer: &irc.SOCKS5Dialer{Addr: "localhost:9050"},
+//	}
+//	conn, err := irc.BaseDialContext(ctx, d, "irc.example.org:6697")
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+var _ Dialer = (*net.Dialer)(nil)
+
+// TLSDialer dials a connection using another Dialer, then performs a TLS
+// handshake over it.
+type TLSDialer struct {
+	// Dialer is used to make the underlying connection. If nil, a zero
+	// net.Dialer is used.
+	Dialer Dialer
+
+	// Config is the TLS configuration. If nil, the default configuration is
+	// used. If ServerName is empty, the host of the dialed address is used.
+	Config *tls.Config
+}
+
+var _ Dialer = (*TLSDialer)(nil)
+
+// DialContext connects to addr and performs a TLS handshake.
+func (d *TLSDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := forwardDialer(d.Dialer).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var config *tls.Config
+	if d.Config == nil {
+		config = &tls.Config{}
+	} else {
+		config = d.Config.Clone()
+	}
+
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		config.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+func forwardDialer(d Dialer) Dialer {
+	if d == nil {
+		return &net.Dialer{}
+	}
+	return d
+}
+
+// handshake runs f, which performs a blocking handshake over conn, such that
+// the handshake is interrupted when the context is done. On error, conn is
+// closed.
+func handshake(ctx context.Context, conn net.Conn, f func() error) error {
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		conn.SetDeadline(deadline) //nolint:errcheck
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// Unblock any pending reads or writes.
+			conn.SetDeadline(time.Unix(1, 0)) //nolint:errcheck
+		case <-done:
+		}
+	}()
+
+	err := f()
+
+	close(done)
+	<-stopped
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	} else if hasDeadline && errors.Is(err, os.ErrDeadlineExceeded) {
+		// The connection's deadline, which is the context's, can pass
+		// just before the context notices.
+		err = context.DeadlineExceeded
+	}
+
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	conn.SetDeadline(time.Time{}) //nolint:errcheck
+	return nil
+}
+
+// bufferedConn is a net.Conn which first reads any data left in a
+// bufio.Reader used during a handshake.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	if b.r.Buffered() > 0 {
+		return b.r.Read(p)
+	}
+	return b.Conn.Read(p)
+}
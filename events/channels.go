@@ -0,0 +1,199 @@
+package events
+
+import "github.com/jakebailey/irc"
+
+// Join is a JOIN of a single channel. With extended-join, the server
+// includes the user's account and real name.
+type Join struct {
+	Meta
+	Channel string
+
+	// Key is the channel key, when joining.
+	Key string
+
+	// Account and RealName are sent with extended-join. Account is empty if
+	// the user is not logged in.
+	Account  string
+	RealName string
+}
+
+// DecodeJoin decodes a JOIN.
+func DecodeJoin(m *irc.Message) (*Join, error) {
+	params, err := params(m, "JOIN", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Join{Meta: meta(m), Channel: params[0]}
+
+	// JOIN <channel> <account> :<realname> with extended-join, or
+	// JOIN <channel> <key> from a client.
+	if len(params) > 2 {
+		j.Account = irc.ParseAccount(params[1])
+		j.RealName = params[2]
+	} else {
+		j.Key = param(params, 1)
+	}
+
+	return j, nil
+}
+
+// Encode returns the JOIN as a message. If Account or RealName is set, the
+// extended-join form is used.
+func (j *Join) Encode() *irc.Message {
+	if j.Account != "" || j.RealName != "" {
+		account := j.Account
+		if account == "" {
+			account = "*"
+		}
+
+		m := j.message("JOIN", j.Channel, account)
+		m.Trailing = j.RealName
+		m.ForcedTrailing = true
+		return m
+	}
+
+	if j.Key != "" {
+		return j.message("JOIN", j.Channel, j.Key)
+	}
+	return j.message("JOIN", j.Channel)
+}
+
+// Part is a PART of a single channel.
+type Part struct {
+	Meta
+	Channel string
+	Reason  string
+}
+
+// DecodePart decodes a PART.
+func DecodePart(m *irc.Message) (*Part, error) {
+	params, err := params(m, "PART", 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Part{Meta: meta(m), Channel: params[0], Reason: param(params, 1)}, nil
+}
+
+// Encode returns the PART as a message.
+func (p *Part) Encode() *irc.Message {
+	m := p.message("PART", p.Channel)
+	m.Trailing = p.Reason
+	return m
+}
+
+// Kick is a KICK of a user from a channel.
+type Kick struct {
+	Meta
+	Channel string
+	Nick    string
+	Reason  string
+}
+
+// DecodeKick decodes a KICK.
+func DecodeKick(m *irc.Message) (*Kick, error) {
+	params, err := params(m, "KICK", 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Kick{Meta: meta(m), Channel: params[0], Nick: params[1], Reason: param(params, 2)}, nil
+}
+
+// Encode returns the KICK as a message.
+func (k *Kick) Encode() *irc.Message {
+	m := k.message("KICK", k.Channel, k.Nick)
+	m.Trailing = k.Reason
+	return m
+}
+
+// Mode is a MODE change of a channel or user.
+type Mode struct {
+	Meta
+	Target string
+
+	// Modes is the mode string, for example "+ov-k".
+	Modes string
+
+	// Params are the modes' parameters.
+	Params []string
+}
+
+// DecodeMode decodes a MODE.
+func DecodeMode(m *irc.Message) (*Mode, error) {
+	params, err := params(m, "MODE", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := &Mode{Meta: meta(m), Target: params[0], Modes: param(params, 1)}
+	if len(params) > 2 {
+		mode.Params = params[2:]
+	}
+
+	return mode, nil
+}
+
+// Encode returns the MODE as a message.
+func (mo *Mode) Encode() *irc.Message {
+	params := []string{mo.Target}
+	if mo.Modes != "" {
+		params = append(params, mo.Modes)
+		params = append(params, mo.Params...)
+	}
+	return mo.message("MODE", params...)
+}
+
+// Changes parses a channel mode change, using is to determine which modes
+// take parameters.
+func (mo *Mode) Changes(is *irc.ISupport) []irc.ModeChange {
+	if mo.Modes == "" {
+		return nil
+	}
+	return irc.ParseChannelModes(is, append([]string{mo.Modes}, mo.Params...))
+}
+
+// Topic is a change of a channel's topic. An empty Topic clears it.
+type Topic struct {
+	Meta
+	Channel string
+	Topic   string
+}
+
+// DecodeTopic decodes a TOPIC. A query for the topic, which has no topic
+// parameter, is not a change, so is rejected.
+func DecodeTopic(m *irc.Message) (*Topic, error) {
+	params, err := params(m, "TOPIC", 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Topic{Meta: meta(m), Channel: params[0], Topic: params[1]}, nil
+}
+
+// Encode returns the TOPIC as a message.
+func (t *Topic) Encode() *irc.Message {
+	m := t.message("TOPIC", t.Channel)
+	m.Trailing = t.Topic
+	m.ForcedTrailing = true
+	return m
+}
+
+// Invite is an INVITE of a user to a channel.
+type Invite struct {
+	Meta
+	Nick    string
+	Channel string
+}
+
+// DecodeInvite decodes an INVITE.
+func DecodeInvite(m *irc.Message) (*Invite, error) {
+	params, err := params(m, "INVITE", 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Invite{Meta: meta(m), Nick: params[0], Channel: params[1]}, nil
+}
+
+// Encode returns the INVITE as a message.
+func (i *Invite) Encode() *irc.Message {
+	return i.message("INVITE", i.Nick, i.Channel)
+}
@@ -0,0 +1,93 @@
+package events
+
+import "github.com/jakebailey/irc"
+
+// Ping is a PING, which must be answered with a PONG with the same token.
+type Ping struct {
+	Meta
+	Token string
+}
+
+// DecodePing decodes a PING.
+func DecodePing(m *irc.Message) (*Ping, error) {
+	params, err := params(m, "PING", 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Ping{Meta: meta(m), Token: params[len(params)-1]}, nil
+}
+
+// Encode returns the PING as a message.
+func (p *Ping) Encode() *irc.Message {
+	m := p.message("PING")
+	m.Trailing = p.Token
+	m.ForcedTrailing = true
+	return m
+}
+
+// Pong returns the reply to the PING.
+func (p *Ping) Pong() *Pong {
+	return &Pong{Token: p.Token}
+}
+
+// Pong is a PONG, the reply to a PING.
+type Pong struct {
+	Meta
+
+	// Server is the server which sent the PONG, which servers include
+	// before the token.
+	Server string
+
+	Token string
+}
+
+// DecodePong decodes a PONG.
+func DecodePong(m *irc.Message) (*Pong, error) {
+	params, err := params(m, "PONG", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pong{Meta: meta(m), Token: params[len(params)-1]}
+	if len(params) > 1 {
+		p.Server = params[0]
+	}
+
+	return p, nil
+}
+
+// Encode returns the PONG as a message.
+func (p *Pong) Encode() *irc.Message {
+	var m *irc.Message
+	if p.Server != "" {
+		m = p.message("PONG", p.Server)
+	} else {
+		m = p.message("PONG")
+	}
+	m.Trailing = p.Token
+	m.ForcedTrailing = true
+	return m
+}
+
+// Error is an ERROR, sent by the server before it closes the connection.
+type Error struct {
+	Meta
+	Reason string
+}
+
+// DecodeError decodes an ERROR.
+func DecodeError(m *irc.Message) (*Error, error) {
+	params, err := params(m, "ERROR", 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Error{Meta: meta(m), Reason: params[0]}, nil
+}
+
+// Encode returns the ERROR as a message.
+func (e *Error) Encode() *irc.Message {
+	m := e.message("ERROR")
+	m.Trailing = e.Reason
+	m.ForcedTrailing = true
+	return m
+}
@@ -0,0 +1,101 @@
+// Package events provides typed structs for common IRC commands, which are
+// decoded from and encoded to irc.Messages.
+package events
+
+import (
+	"github.com/jakebailey/irc"
+)
+
+// Event is a decoded message.
+type Event interface {
+	// Encode returns the event as a message.
+	Encode() *irc.Message
+}
+
+// Meta holds the parts of a message common to every event. It is embedded
+// in each event.
+type Meta struct {
+	Tags   map[string]string
+	Prefix irc.Prefix
+}
+
+func meta(m *irc.Message) Meta {
+	return Meta{Tags: m.Tags, Prefix: m.Prefix}
+}
+
+func (mt Meta) message(command string, params ...string) *irc.Message {
+	return &irc.Message{
+		Tags:    mt.Tags,
+		Prefix:  mt.Prefix,
+		Command: command,
+		Params:  params,
+	}
+}
+
+// MessageError is returned when a message cannot be decoded as an event,
+// because it has the wrong command or too few parameters.
+type MessageError struct {
+	Command string
+	Reason  string
+}
+
+func (e *MessageError) Error() string {
+	return "irc: cannot decode " + e.Command + ": " + e.Reason
+}
+
+// decoders maps commands to their decoders, for Decode.
+var decoders = map[string]func(*irc.Message) (Event, error){
+	"PRIVMSG": func(m *irc.Message) (Event, error) { return DecodePrivmsg(m) },
+	"NOTICE":  func(m *irc.Message) (Event, error) { return DecodeNotice(m) },
+	"TAGMSG":  func(m *irc.Message) (Event, error) { return DecodeTagmsg(m) },
+	"JOIN":    func(m *irc.Message) (Event, error) { return DecodeJoin(m) },
+	"PART":    func(m *irc.Message) (Event, error) { return DecodePart(m) },
+	"KICK":    func(m *irc.Message) (Event, error) { return DecodeKick(m) },
+	"MODE":    func(m *irc.Message) (Event, error) { return DecodeMode(m) },
+	"TOPIC":   func(m *irc.Message) (Event, error) { return DecodeTopic(m) },
+	"INVITE":  func(m *irc.Message) (Event, error) { return DecodeInvite(m) },
+	"QUIT":    func(m *irc.Message) (Event, error) { return DecodeQuit(m) },
+	"NICK":    func(m *irc.Message) (Event, error) { return DecodeNick(m) },
+	"AWAY":    func(m *irc.Message) (Event, error) { return DecodeAway(m) },
+	"ACCOUNT": func(m *irc.Message) (Event, error) { return DecodeAccount(m) },
+	"CHGHOST": func(m *irc.Message) (Event, error) { return DecodeChghost(m) },
+	"SETNAME": func(m *irc.Message) (Event, error) { return DecodeSetname(m) },
+	"PING":    func(m *irc.Message) (Event, error) { return DecodePing(m) },
+	"PONG":    func(m *irc.Message) (Event, error) { return DecodePong(m) },
+	"ERROR":   func(m *irc.Message) (Event, error) { return DecodeError(m) },
+}
+
+// Decode decodes a message as the event for its command, such as a
+// *Privmsg for PRIVMSG. A *MessageError is returned if the command has no
+// event type, or the message is invalid.
+func Decode(m *irc.Message) (Event, error) {
+	decode, ok := decoders[m.Command]
+	if !ok {
+		return nil, &MessageError{Command: m.Command, Reason: "unknown command"}
+	}
+	return decode(m)
+}
+
+// params checks a message's command and parameter count, returning its
+// parameters, including the trailing parameter.
+func params(m *irc.Message, command string, min int) ([]string, error) {
+	if m.Command != command {
+		return nil, &MessageError{Command: command, Reason: "message is " + m.Command}
+	}
+
+	params := m.AllParams()
+
+	if len(params) < min {
+		return nil, &MessageError{Command: command, Reason: "not enough parameters"}
+	}
+
+	return params, nil
+}
+
+// param returns params[i], or the empty string if there are too few.
+func param(params []string, i int) string {
+	if i < len(params) {
+		return params[i]
+	}
+	return ""
+}
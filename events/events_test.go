@@ -0,0 +1,104 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+var alice = irc.Prefix{Name: "alice", User: "a", Host: "a.org"}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		line  string
+		event Event
+	}{
+		{":alice!a@a.org PRIVMSG #chan :hello there", &Privmsg{Meta: Meta{Prefix: alice}, Target: "#chan", Text: "hello there"}},
+		{":alice!a@a.org PRIVMSG #chan :", &Privmsg{Meta: Meta{Prefix: alice}, Target: "#chan"}},
+		{":alice!a@a.org NOTICE bob :hi", &Notice{Meta: Meta{Prefix: alice}, Target: "bob", Text: "hi"}},
+		{"@+typing=active :alice!a@a.org TAGMSG #chan", &Tagmsg{Meta: Meta{Tags: map[string]string{"+typing": "active"}, Prefix: alice}, Target: "#chan"}},
+		{":alice!a@a.org JOIN #chan", &Join{Meta: Meta{Prefix: alice}, Channel: "#chan"}},
+		{"JOIN #chan key", &Join{Channel: "#chan", Key: "key"}},
+		{":alice!a@a.org JOIN #chan alice_acct :Alice A", &Join{Meta: Meta{Prefix: alice}, Channel: "#chan", Account: "alice_acct", RealName: "Alice A"}},
+		{":alice!a@a.org PART #chan :Bye all", &Part{Meta: Meta{Prefix: alice}, Channel: "#chan", Reason: "Bye all"}},
+		{":alice!a@a.org PART #chan", &Part{Meta: Meta{Prefix: alice}, Channel: "#chan"}},
+		{":alice!a@a.org KICK #chan bob :Go away", &Kick{Meta: Meta{Prefix: alice}, Channel: "#chan", Nick: "bob", Reason: "Go away"}},
+		{":alice!a@a.org MODE #chan +ov bob carol", &Mode{Meta: Meta{Prefix: alice}, Target: "#chan", Modes: "+ov", Params: []string{"bob", "carol"}}},
+		{"MODE #chan", &Mode{Target: "#chan"}},
+		{":alice!a@a.org TOPIC #chan :New topic", &Topic{Meta: Meta{Prefix: alice}, Channel: "#chan", Topic: "New topic"}},
+		{":alice!a@a.org TOPIC #chan :", &Topic{Meta: Meta{Prefix: alice}, Channel: "#chan"}},
+		{":alice!a@a.org INVITE bob #chan", &Invite{Meta: Meta{Prefix: alice}, Nick: "bob", Channel: "#chan"}},
+		{":alice!a@a.org QUIT :Quit: leaving", &Quit{Meta: Meta{Prefix: alice}, Reason: "Quit: leaving"}},
+		{":alice!a@a.org NICK alice_", &Nick{Meta: Meta{Prefix: alice}, Nick: "alice_"}},
+		{":alice!a@a.org AWAY :Gone fishing", &Away{Meta: Meta{Prefix: alice}, Message: "Gone fishing"}},
+		{":alice!a@a.org AWAY", &Away{Meta: Meta{Prefix: alice}}},
+		{":alice!a@a.org ACCOUNT alice_acct", &Account{Meta: Meta{Prefix: alice}, Account: "alice_acct"}},
+		{":alice!a@a.org ACCOUNT *", &Account{Meta: Meta{Prefix: alice}}},
+		{":alice!a@a.org CHGHOST ~alice new.host", &Chghost{Meta: Meta{Prefix: alice}, User: "~alice", Host: "new.host"}},
+		{":alice!a@a.org SETNAME :Alice Liddell", &Setname{Meta: Meta{Prefix: alice}, RealName: "Alice Liddell"}},
+		{"PING :irc.example.org", &Ping{Token: "irc.example.org"}},
+		{":irc.example.org PONG irc.example.org :token", &Pong{Meta: Meta{Prefix: irc.Prefix{Name: "irc.example.org"}}, Server: "irc.example.org", Token: "token"}},
+		{"ERROR :Closing Link: timeout", &Error{Reason: "Closing Link: timeout"}},
+	}
+
+	for _, test := range tests {
+		m, err := irc.ParseMessage(test.line)
+		assert.NoError(t, err)
+
+		event, err := Decode(m)
+		assert.NoError(t, err, test.line)
+		assert.Equal(t, test.event, event, test.line)
+
+		if event != nil {
+			assert.Equal(t, test.line, event.Encode().String(), test.line)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, line := range []string{
+		"PRIVMSG #chan",
+		"KICK #chan",
+		"TOPIC #chan",
+		"INVITE bob",
+		"CHGHOST ~alice",
+		"PING",
+		"FOO bar",
+	} {
+		m, err := irc.ParseMessage(line)
+		assert.NoError(t, err)
+
+		_, err = Decode(m)
+
+		var msgErr *MessageError
+		assert.True(t, errors.As(err, &msgErr), line)
+	}
+
+	_, err := DecodeNick(&irc.Message{Command: "PRIVMSG", Params: []string{"#chan", "hi"}})
+	assert.EqualError(t, err, "irc: cannot decode NICK: message is PRIVMSG")
+}
+
+func TestPrivmsgCTCP(t *testing.T) {
+	p := &Privmsg{Target: "#chan", Text: "\x01ACTION waves\x01"}
+
+	command, args, ok := p.CTCP()
+	assert.True(t, ok)
+	assert.Equal(t, "ACTION", command)
+	assert.Equal(t, "waves", args)
+}
+
+func TestModeChanges(t *testing.T) {
+	mode := &Mode{Target: "#chan", Modes: "+ok", Params: []string{"bob", "secret"}}
+
+	assert.Equal(t, []irc.ModeChange{
+		{Add: true, Mode: 'o', Param: "bob"},
+		{Add: true, Mode: 'k', Param: "secret"},
+	}, mode.Changes(&irc.ISupport{}))
+}
+
+func TestPingPong(t *testing.T) {
+	ping := &Ping{Token: "abc"}
+	assert.Equal(t, "PONG :abc", ping.Pong().Encode().String())
+}
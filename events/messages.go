@@ -0,0 +1,82 @@
+package events
+
+import "github.com/jakebailey/irc"
+
+// Privmsg is a PRIVMSG: a message to a channel or user.
+type Privmsg struct {
+	Meta
+	Target string
+	Text   string
+}
+
+// DecodePrivmsg decodes a PRIVMSG.
+func DecodePrivmsg(m *irc.Message) (*Privmsg, error) {
+	params, err := params(m, "PRIVMSG", 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Privmsg{Meta: meta(m), Target: params[0], Text: params[1]}, nil
+}
+
+// Encode returns the PRIVMSG as a message.
+func (p *Privmsg) Encode() *irc.Message {
+	m := p.message("PRIVMSG", p.Target)
+	m.Trailing = p.Text
+	m.ForcedTrailing = true
+	return m
+}
+
+// CTCP parses the message as a CTCP, such as an ACTION.
+func (p *Privmsg) CTCP() (command, args string, ok bool) {
+	return irc.ParseCTCP(p.Text)
+}
+
+// Notice is a NOTICE, which clients must not automatically reply to.
+type Notice struct {
+	Meta
+	Target string
+	Text   string
+}
+
+// DecodeNotice decodes a NOTICE.
+func DecodeNotice(m *irc.Message) (*Notice, error) {
+	params, err := params(m, "NOTICE", 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Notice{Meta: meta(m), Target: params[0], Text: params[1]}, nil
+}
+
+// Encode returns the NOTICE as a message.
+func (n *Notice) Encode() *irc.Message {
+	m := n.message("NOTICE", n.Target)
+	m.Trailing = n.Text
+	m.ForcedTrailing = true
+	return m
+}
+
+// CTCP parses the notice as a CTCP reply.
+func (n *Notice) CTCP() (command, args string, ok bool) {
+	return irc.ParseCTCP(n.Text)
+}
+
+// Tagmsg is a TAGMSG, which carries only tags (from message-tags), such as
+// typing notifications.
+type Tagmsg struct {
+	Meta
+	Target string
+}
+
+// DecodeTagmsg decodes a TAGMSG.
+func DecodeTagmsg(m *irc.Message) (*Tagmsg, error) {
+	params, err := params(m, "TAGMSG", 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Tagmsg{Meta: meta(m), Target: params[0]}, nil
+}
+
+// Encode returns the TAGMSG as a message.
+func (t *Tagmsg) Encode() *irc.Message {
+	return t.message("TAGMSG", t.Target)
+}
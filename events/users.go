@@ -0,0 +1,147 @@
+package events
+
+import "github.com/jakebailey/irc"
+
+// Quit is a QUIT.
+type Quit struct {
+	Meta
+	Reason string
+}
+
+// DecodeQuit decodes a QUIT.
+func DecodeQuit(m *irc.Message) (*Quit, error) {
+	params, err := params(m, "QUIT", 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Quit{Meta: meta(m), Reason: param(params, 0)}, nil
+}
+
+// Encode returns the QUIT as a message.
+func (q *Quit) Encode() *irc.Message {
+	m := q.message("QUIT")
+	m.Trailing = q.Reason
+	return m
+}
+
+// Nick is a NICK change.
+type Nick struct {
+	Meta
+	Nick string
+}
+
+// DecodeNick decodes a NICK.
+func DecodeNick(m *irc.Message) (*Nick, error) {
+	params, err := params(m, "NICK", 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Nick{Meta: meta(m), Nick: params[0]}, nil
+}
+
+// Encode returns the NICK as a message.
+func (n *Nick) Encode() *irc.Message {
+	return n.message("NICK", n.Nick)
+}
+
+// Away is an AWAY, which is sent to set or unset the client's away
+// message, and (with away-notify) by the server when a user's changes.
+type Away struct {
+	Meta
+
+	// Message is the away message, or empty if the user is back.
+	Message string
+}
+
+// DecodeAway decodes an AWAY.
+func DecodeAway(m *irc.Message) (*Away, error) {
+	params, err := params(m, "AWAY", 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Away{Meta: meta(m), Message: param(params, 0)}, nil
+}
+
+// Encode returns the AWAY as a message.
+func (a *Away) Encode() *irc.Message {
+	m := a.message("AWAY")
+	m.Trailing = a.Message
+	return m
+}
+
+// IsAway returns true if the user is away.
+func (a *Away) IsAway() bool {
+	return a.Message != ""
+}
+
+// Account is an ACCOUNT (from account-notify), sent when a user logs in or
+// out.
+type Account struct {
+	Meta
+
+	// Account is the user's account, or empty if they logged out.
+	Account string
+}
+
+// DecodeAccount decodes an ACCOUNT.
+func DecodeAccount(m *irc.Message) (*Account, error) {
+	params, err := params(m, "ACCOUNT", 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{Meta: meta(m), Account: irc.ParseAccount(params[0])}, nil
+}
+
+// Encode returns the ACCOUNT as a message.
+func (a *Account) Encode() *irc.Message {
+	if a.Account == "" {
+		return a.message("ACCOUNT", "*")
+	}
+	return a.message("ACCOUNT", a.Account)
+}
+
+// Chghost is a CHGHOST (from chghost), sent when a user's username or host
+// changes.
+type Chghost struct {
+	Meta
+	User string
+	Host string
+}
+
+// DecodeChghost decodes a CHGHOST.
+func DecodeChghost(m *irc.Message) (*Chghost, error) {
+	params, err := params(m, "CHGHOST", 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Chghost{Meta: meta(m), User: params[0], Host: params[1]}, nil
+}
+
+// Encode returns the CHGHOST as a message.
+func (c *Chghost) Encode() *irc.Message {
+	return c.message("CHGHOST", c.User, c.Host)
+}
+
+// Setname is a SETNAME (from setname), sent to change the client's real
+// name, and by the server when a user's changes.
+type Setname struct {
+	Meta
+	RealName string
+}
+
+// DecodeSetname decodes a SETNAME.
+func DecodeSetname(m *irc.Message) (*Setname, error) {
+	params, err := params(m, "SETNAME", 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Setname{Meta: meta(m), RealName: params[0]}, nil
+}
+
+// Encode returns the SETNAME as a message.
+func (s *Setname) Encode() *irc.Message {
+	m := s.message("SETNAME")
+	m.Trailing = s.RealName
+	m.ForcedTrailing = true
+	return m
+}
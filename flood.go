@@ -0,0 +1,177 @@
+package irc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FloodControl is an Encoder which limits the rate at which messages are
+// passed to another Encoder, to avoid being disconnected by the server for
+// flooding.
+//
+// FloodControl implements a token bucket using a "message timer", as
+// described in RFC 1459 section 8.10 and used (with variations) by most
+// ircds. Each message advances the timer by its cost. A message may be sent
+// immediately as long as doing so leaves the timer no further than the burst
+// window ahead of the current time; otherwise the sender waits until it would.
+type FloodControl struct {
+	e      Encoder
+	window time.Duration
+	cost   func(*Message) time.Duration
+
+	mu    sync.Mutex
+	timer time.Time
+
+	// Replaced in tests.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+var _ Encoder = (*FloodControl)(nil)
+
+// FloodOptions configures a FloodControl.
+type FloodOptions struct {
+	// Burst is the number of messages (of cost Interval) which may be sent
+	// back to back before sending is paced. If zero, 5 is used.
+	Burst int
+
+	// Interval is the time it takes for one message's worth of budget to
+	// become available again. If zero, 2 seconds is used.
+	Interval time.Duration
+
+	// BytesPerInterval, if positive, weights the cost of a message by its
+	// encoded length. Each message costs one Interval, plus one additional
+	// Interval for every BytesPerInterval bytes.
+	BytesPerInterval int
+
+	// Cost, if set, overrides the cost computation, returning the amount
+	// of time that sending a message adds to the message timer.
+	Cost func(*Message) time.Duration
+}
+
+// RFC1459Flood are the options which match the flood control described in
+// RFC 1459 section 8.10: each message adds two seconds to the message timer,
+// and messages are delayed once the timer is ten seconds ahead.
+var RFC1459Flood = FloodOptions{
+	Burst:    5,
+	Interval: 2 * time.Second,
+}
+
+// PenaltyFlood are options which additionally penalize long messages, as
+// many servers do, charging an extra two seconds for every 120 bytes.
+var PenaltyFlood = FloodOptions{
+	Burst:            5,
+	Interval:         2 * time.Second,
+	BytesPerInterval: 120,
+}
+
+// NewFloodControl creates a new FloodControl which sends messages to e.
+func NewFloodControl(e Encoder, opts FloodOptions) *FloodControl {
+	if opts.Burst <= 0 {
+		opts.Burst = 5
+	}
+
+	if opts.Interval <= 0 {
+		opts.Interval = 2 * time.Second
+	}
+
+	cost := opts.Cost
+	if cost == nil {
+		interval := opts.Interval
+		perBytes := opts.BytesPerInterval
+
+		cost = func(m *Message) time.Duration {
+			if perBytes <= 0 {
+				return interval
+			}
+			return interval * time.Duration(1+m.Len()/perBytes)
+		}
+	}
+
+	return &FloodControl{
+		e:      e,
+		window: opts.Interval * time.Duration(opts.Burst),
+		cost:   cost,
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+// Encode waits until the message may be sent, then encodes it using the
+// wrapped Encoder.
+func (f *FloodControl) Encode(m *Message) error {
+	return f.EncodeContext(context.Background(), m)
+}
+
+// EncodeContext waits until the message may be sent, then encodes it using
+// the wrapped Encoder. If the context has a deadline which would pass before
+// the message can be sent, EncodeContext fails immediately with
+// context.DeadlineExceeded rather than waiting. If the context is cancelled
+// while waiting, its error is returned and the message is not sent.
+func (f *FloodControl) EncodeContext(ctx context.Context, m *Message) error {
+	wait, cost, err := f.reserve(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	if wait > 0 {
+		if err := f.sleep(ctx, wait); err != nil {
+			// The message was not sent, so give back its budget.
+			f.refund(cost)
+			return err
+		}
+	}
+
+	return f.e.Encode(m)
+}
+
+func (f *FloodControl) reserve(ctx context.Context, m *Message) (wait, cost time.Duration, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+
+	timer := f.timer
+	if timer.Before(now) {
+		timer = now
+	}
+	cost = f.cost(m)
+	timer = timer.Add(cost)
+
+	wait = timer.Sub(now) - f.window
+	if wait < 0 {
+		wait = 0
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		return 0, 0, context.DeadlineExceeded
+	}
+
+	f.timer = timer
+
+	return wait, cost, nil
+}
+
+func (f *FloodControl) refund(cost time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.timer = f.timer.Add(-cost)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
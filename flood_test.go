@@ -0,0 +1,162 @@
+package irc
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingEncoder struct {
+	mu       sync.Mutex
+	messages []*Message
+	err      error
+}
+
+func (r *recordingEncoder) Encode(m *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func (r *recordingEncoder) commands() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []string
+	for _, m := range r.messages {
+		out = append(out, m.Command)
+	}
+	return out
+}
+
+type fakeClock struct {
+	t     time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) install(f *FloodControl) {
+	f.now = func() time.Time { return c.t }
+	f.sleep = func(ctx context.Context, d time.Duration) error {
+		c.slept = append(c.slept, d)
+		return nil
+	}
+}
+
+func TestFloodControlBurst(t *testing.T) {
+	r := &recordingEncoder{}
+	f := NewFloodControl(r, RFC1459Flood)
+	c := &fakeClock{t: time.Unix(1000, 0)}
+	c.install(f)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+	}
+	assert.Empty(t, c.slept)
+
+	assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+	assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+	assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second}, c.slept)
+	assert.Len(t, r.messages, 7)
+
+	// After the timer has run down, the burst is available again.
+	c.slept = nil
+	c.t = c.t.Add(time.Minute)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+	}
+	assert.Empty(t, c.slept)
+}
+
+func TestFloodControlLength(t *testing.T) {
+	r := &recordingEncoder{}
+	f := NewFloodControl(r, PenaltyFlood)
+	c := &fakeClock{t: time.Unix(1000, 0)}
+	c.install(f)
+
+	long := &Message{Command: "PRIVMSG", Params: []string{"#chan"}, Trailing: strings.Repeat("a", 300)}
+
+	// Each message costs three units (six seconds), so the second must wait
+	// for the timer to drop back within the ten second window.
+	assert.NoError(t, f.Encode(long))
+	assert.NoError(t, f.Encode(long))
+	assert.Equal(t, []time.Duration{2 * time.Second}, c.slept)
+}
+
+func TestFloodControlDeadline(t *testing.T) {
+	r := &recordingEncoder{}
+	f := NewFloodControl(r, FloodOptions{Burst: 1, Interval: time.Hour})
+	c := &fakeClock{t: time.Now()}
+	c.install(f)
+
+	assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+
+	ctx, cancel := context.WithDeadline(context.Background(), c.t.Add(time.Minute))
+	defer cancel()
+
+	err := f.EncodeContext(ctx, &Message{Command: "PRIVMSG"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Len(t, r.messages, 1)
+	assert.Empty(t, c.slept)
+
+	// A failed reservation does not consume budget.
+	c.t = c.t.Add(time.Hour)
+	assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+	assert.Len(t, r.messages, 2)
+	assert.Empty(t, c.slept)
+}
+
+func TestFloodControlCancel(t *testing.T) {
+	r := &recordingEncoder{}
+	f := NewFloodControl(r, FloodOptions{Burst: 1, Interval: time.Hour})
+
+	assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	err := f.EncodeContext(ctx, &Message{Command: "PRIVMSG"})
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, r.messages, 1)
+}
+
+func TestFloodControlCancelRefund(t *testing.T) {
+	r := &recordingEncoder{}
+	f := NewFloodControl(r, FloodOptions{Burst: 1, Interval: time.Hour})
+	c := &fakeClock{t: time.Now()}
+	c.install(f)
+
+	assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+
+	f.sleep = func(ctx context.Context, d time.Duration) error {
+		c.slept = append(c.slept, d)
+		return context.Canceled
+	}
+
+	err := f.Encode(&Message{Command: "PRIVMSG"})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []time.Duration{time.Hour}, c.slept)
+
+	// Once the first message's cost has passed, the next is not delayed by
+	// the cancelled one.
+	c.install(f)
+	c.slept = nil
+	c.t = c.t.Add(time.Hour)
+
+	assert.NoError(t, f.Encode(&Message{Command: "PRIVMSG"}))
+	assert.Empty(t, c.slept)
+	assert.Len(t, r.messages, 2)
+}
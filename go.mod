@@ -2,7 +2,10 @@ module github.com/jakebailey/irc
 
 go 1.18
 
-require github.com/stretchr/testify v1.8.1
+require (
+	github.com/gorilla/websocket v1.5.0
+	github.com/stretchr/testify v1.8.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
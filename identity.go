@@ -0,0 +1,192 @@
+package irc
+
+import (
+	"strings"
+	"sync"
+)
+
+// Limits assumed by Identity.PrefixLen for parts of the prefix which are
+// not yet known.
+const (
+	maxUserLen = 10
+	maxHostLen = 63
+)
+
+// Identity tracks the client's own nick, username, host and account, as
+// the server changes them. It is safe for concurrent use. The zero value is
+// ready to use.
+//
+// Pass it to Register in RegisterOptions to track registration (including
+// SASL), then pass every message after registration to Handle, which
+// irchandle.Identity does as a middleware.
+type Identity struct {
+	// ISupport, if set, provides the casemapping used to recognize the
+	// client's own nick. It must be set before the Identity is used, but
+	// its contents may be updated later. If nil, the rfc1459 casemapping is
+	// used.
+	ISupport *ISupport
+
+	mu      sync.RWMutex
+	nick    string
+	user    string
+	host    string
+	account string
+}
+
+// Nick returns the client's current nick.
+func (i *Identity) Nick() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.nick
+}
+
+// Prefix returns the client's prefix, as other clients see it. User and
+// Host are empty until the server has revealed them.
+func (i *Identity) Prefix() Prefix {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return Prefix{Name: i.nick, User: i.user, Host: i.host}
+}
+
+// Account returns the account the client is logged in to, or the empty
+// string.
+func (i *Identity) Account() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.account
+}
+
+// PrefixLen returns the length of the prefix (including the leading colon
+// and trailing space) which the server adds to the client's messages when
+// relaying them, which counts against the 512 byte line limit. If the
+// username or host is not known, a conservative estimate is used.
+func (i *Identity) PrefixLen() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	userLen := len(i.user)
+	if userLen == 0 {
+		userLen = maxUserLen
+	}
+
+	hostLen := len(i.host)
+	if hostLen == 0 {
+		hostLen = maxHostLen
+	}
+
+	// ":nick!user@host "
+	return 1 + len(i.nick) + 1 + userLen + 1 + hostLen + 1
+}
+
+// Reset clears all state, as is needed before registering a new
+// connection. Register calls Reset.
+func (i *Identity) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.nick = ""
+	i.user = ""
+	i.host = ""
+	i.account = ""
+}
+
+// Handle updates the identity from a message. Messages which do not
+// concern the client are ignored.
+func (i *Identity) Handle(m *Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	self := i.nick != "" && i.isNick(m.Prefix.Name)
+
+	// Any message from the client reveals its username and host.
+	if self && m.Prefix.User != "" && m.Prefix.Host != "" {
+		i.user = m.Prefix.User
+		i.host = m.Prefix.Host
+	}
+
+	params := m.AllParams()
+
+	switch m.Command {
+	case RPL_WELCOME:
+		if len(params) > 0 {
+			i.nick = params[0]
+		}
+
+		// Many servers end the welcome message with the full prefix.
+		if len(params) > 1 {
+			fields := strings.Fields(params[len(params)-1])
+			if len(fields) > 0 {
+				p := ParsePrefix(fields[len(fields)-1])
+				if p.User != "" && p.Host != "" && i.isNick(p.Name) {
+					i.user = p.User
+					i.host = p.Host
+				}
+			}
+		}
+
+	case "NICK":
+		if self && len(params) > 0 {
+			i.nick = params[0]
+		}
+
+	case "CHGHOST":
+		if self && len(params) > 1 {
+			i.user = params[0]
+			i.host = params[1]
+		}
+
+	case "ACCOUNT":
+		if self && len(params) > 0 {
+			i.account = ParseAccount(params[0])
+		}
+
+	case RPL_HOSTHIDDEN:
+		// <nick> <[user@]host> :is now your displayed host
+		if len(params) > 1 {
+			host := params[1]
+			if j := strings.IndexByte(host, '@'); j != -1 {
+				i.user = host[:j]
+				host = host[j+1:]
+			}
+			i.host = host
+		}
+
+	case RPL_LOGGEDIN:
+		// <nick> <nick>!<user>@<host> <account> :You are now logged in
+		if len(params) > 2 {
+			i.setPrefix(params[1])
+			i.account = params[2]
+		}
+
+	case RPL_LOGGEDOUT:
+		if len(params) > 1 {
+			i.setPrefix(params[1])
+		}
+		i.account = ""
+	}
+}
+
+// isNick returns true if name is the client's nick. i.mu must be held.
+func (i *Identity) isNick(name string) bool {
+	mapping := CaseMappingRFC1459
+	if i.ISupport != nil {
+		mapping = i.ISupport.CaseMapping()
+	}
+	return mapping.Fold(name) == mapping.Fold(i.nick)
+}
+
+func (i *Identity) setPrefix(s string) {
+	p := ParsePrefix(s)
+	if p.Name != "" && p.Name != "*" {
+		i.nick = p.Name
+	}
+	if p.User != "" {
+		i.user = p.User
+	}
+	if p.Host != "" {
+		i.host = p.Host
+	}
+}
@@ -0,0 +1,87 @@
+package irc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentity(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org CAP * LS :sasl
+		C: CAP REQ :sasl
+		S: :irc.example.org CAP * ACK :sasl
+		C: AUTHENTICATE PLAIN
+		S: AUTHENTICATE +
+		C: AUTHENTICATE AGpha2UAaHVudGVyMg==
+		S: :irc.example.org 900 * *!~jake@198.51.100.7 jbailey :You are now logged in as jbailey
+		S: :irc.example.org 903 * :SASL authentication successful
+		C: CAP END
+		S: :irc.example.org 001 jake :Welcome to the Example network, jake
+		S: :irc.example.org 422 jake :MOTD File is missing
+	`)
+
+	id := &Identity{}
+
+	_, err := Register(context.Background(), conn, RegisterOptions{
+		Nick:     "jake",
+		SASL:     &SASLPlain{Username: "jake", Password: "hunter2"},
+		Identity: id,
+	})
+	assert.NoError(t, err)
+	<-done
+
+	assert.Equal(t, Prefix{Name: "jake", User: "~jake", Host: "198.51.100.7"}, id.Prefix())
+	assert.Equal(t, "jbailey", id.Account())
+	assert.Equal(t, len(":jake!~jake@198.51.100.7 "), id.PrefixLen())
+
+	for _, raw := range []string{
+		":irc.example.org 396 jake user/jake :is now your displayed host",
+		":other!o@h NICK :someone",
+		":JAKE!~jake@user/jake NICK :jake2",
+		":jake2!~jake@user/jake CHGHOST jb example.org",
+		":jake2!jb@example.org ACCOUNT *",
+	} {
+		m, err := ParseMessage(raw)
+		assert.NoError(t, err)
+		id.Handle(m)
+	}
+
+	assert.Equal(t, Prefix{Name: "jake2", User: "jb", Host: "example.org"}, id.Prefix())
+	assert.Empty(t, id.Account())
+}
+
+func TestIdentityWelcomePrefix(t *testing.T) {
+	id := &Identity{}
+	assert.Equal(t, len(":!@ ")+maxUserLen+maxHostLen, id.PrefixLen())
+
+	m, err := ParseMessage(":irc.example.org 001 jake :Welcome to the Internet Relay Network jake!jake@example.org")
+	assert.NoError(t, err)
+	id.Handle(m)
+
+	assert.Equal(t, Prefix{Name: "jake", User: "jake", Host: "example.org"}, id.Prefix())
+}
+
+func TestIdentityCaseMapping(t *testing.T) {
+	is := &ISupport{}
+	id := &Identity{ISupport: is}
+
+	for _, raw := range []string{
+		":irc.example.org 001 jake[ :Welcome",
+		":irc.example.org 005 jake[ CASEMAPPING=ascii :are supported by this server",
+		// Under ascii, jake{ is someone else.
+		":jake{!~jake@example.org NICK :other",
+		":JAKE[!~jake@example.org NICK :jake2",
+	} {
+		m, err := ParseMessage(raw)
+		assert.NoError(t, err)
+		is.Update(m)
+		id.Handle(m)
+	}
+
+	assert.Equal(t, Prefix{Name: "jake2", User: "~jake", Host: "example.org"}, id.Prefix())
+}
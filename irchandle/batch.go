@@ -0,0 +1,208 @@
+package irchandle
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jakebailey/irc"
+)
+
+// DefaultBatchTimeout is the time a batch may remain open before it is
+// delivered incomplete, when BatchOptions.Timeout is zero.
+const DefaultBatchTimeout = time.Minute
+
+// Batch is an IRCv3 batch, collected from a BATCH +ref message, the messages
+// tagged with the batch's reference, and a BATCH -ref message.
+type Batch struct {
+	// Ref is the reference tag the server chose for the batch.
+	Ref string
+
+	// Type is the batch type, for example "netsplit" or "chathistory".
+	Type string
+
+	// Params are the parameters which follow the type.
+	Params []string
+
+	// Start is the BATCH message which opened the batch.
+	Start *irc.Message
+
+	// Messages are the messages in the batch, in the order they were
+	// received, not including nested batches.
+	Messages []*irc.Message
+
+	// Batches are the batches nested in this batch, in the order they were
+	// opened.
+	Batches []*Batch
+
+	// Incomplete is true if the batch timed out, or its parent ended,
+	// before the server ended it.
+	Incomplete bool
+}
+
+// BatchHandler handles a completed batch.
+type BatchHandler interface {
+	HandleBatch(ctx context.Context, e irc.Encoder, b *Batch)
+}
+
+// BatchHandlerFunc is a type adapter to use functions as BatchHandlers.
+type BatchHandlerFunc func(ctx context.Context, e irc.Encoder, b *Batch)
+
+// HandleBatch calls f(ctx, e, b).
+func (f BatchHandlerFunc) HandleBatch(ctx context.Context, e irc.Encoder, b *Batch) {
+	f(ctx, e, b)
+}
+
+// BatchOptions configures Batches.
+type BatchOptions struct {
+	// Timeout is the time a top-level batch may remain open. If it passes,
+	// the batch is delivered with Incomplete set when the next message is
+	// handled. If zero, DefaultBatchTimeout is used.
+	Timeout time.Duration
+}
+
+// Batches returns a middleware which collects batched messages (which
+// requires the "batch" capability) and delivers each top-level batch to bh
+// once it ends, with nested batches included in it. BATCH messages and
+// batched messages are not passed on to the handler; all other messages are.
+//
+// Batches are only delivered while handling a message, never from another
+// goroutine, so a batch which times out is delivered just before the next
+// message is handled. As servers send PING regularly, this is not long.
+//
+// Batches depends on message order, so the Client must be run with Sync.
+func Batches(bh BatchHandler, opts BatchOptions) func(Handler) Handler {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultBatchTimeout
+	}
+
+	return func(handler Handler) Handler {
+		b := &batcher{
+			bh:      bh,
+			timeout: opts.Timeout,
+			open:    make(map[string]*openBatch),
+		}
+
+		return HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+			b.expire(ctx, e, m)
+			if !b.handle(ctx, e, m) {
+				handler.HandleMessage(ctx, e, m)
+			}
+		})
+	}
+}
+
+type openBatch struct {
+	batch *Batch
+
+	// deadline is when a top-level batch times out, and is zero for nested
+	// batches.
+	deadline time.Time
+}
+
+type batcher struct {
+	bh      BatchHandler
+	timeout time.Duration
+	open    map[string]*openBatch
+}
+
+// handle collects m if it belongs to a batch, returning false if it should
+// be passed on.
+func (b *batcher) handle(ctx context.Context, e irc.Encoder, m *irc.Message) bool {
+	parent := b.open[m.Batch()]
+
+	if m.Command != "BATCH" || len(m.Params) == 0 || len(m.Params[0]) < 2 {
+		if parent == nil {
+			return false
+		}
+
+		parent.batch.Messages = append(parent.batch.Messages, m)
+		return true
+	}
+
+	params := m.AllParams()
+	ref := params[0][1:]
+
+	switch params[0][0] {
+	case '+':
+		batch := &Batch{Ref: ref, Start: m}
+		if len(params) > 1 {
+			batch.Type = params[1]
+			batch.Params = params[2:]
+		}
+
+		ob := &openBatch{batch: batch}
+
+		if parent != nil {
+			parent.batch.Batches = append(parent.batch.Batches, batch)
+		} else {
+			ob.deadline = time.Now().Add(b.timeout)
+		}
+
+		b.open[ref] = ob
+
+	case '-':
+		ob := b.open[ref]
+		if ob == nil {
+			return true
+		}
+
+		delete(b.open, ref)
+		b.closeChildren(ob.batch)
+
+		if !ob.deadline.IsZero() {
+			b.bh.HandleBatch(ctx, e, ob.batch)
+		}
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// expire delivers the top-level batches which have timed out, in the order
+// they were opened, other than one which m ends.
+func (b *batcher) expire(ctx context.Context, e irc.Encoder, m *irc.Message) {
+	var ending string
+	if m.Command == "BATCH" && len(m.Params) > 0 && strings.HasPrefix(m.Params[0], "-") {
+		ending = m.Params[0][1:]
+	}
+
+	now := time.Now()
+
+	var expired []*openBatch
+	for ref, ob := range b.open {
+		if ob.deadline.IsZero() || ref == ending || now.Before(ob.deadline) {
+			continue
+		}
+		expired = append(expired, ob)
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].deadline.Before(expired[j].deadline)
+	})
+
+	for _, ob := range expired {
+		delete(b.open, ob.batch.Ref)
+		ob.batch.Incomplete = true
+		b.closeChildren(ob.batch)
+	}
+
+	for _, ob := range expired {
+		b.bh.HandleBatch(ctx, e, ob.batch)
+	}
+}
+
+// closeChildren removes the nested batches of a batch which are still open,
+// marking them incomplete.
+func (b *batcher) closeChildren(batch *Batch) {
+	for _, child := range batch.Batches {
+		if _, ok := b.open[child.Ref]; ok {
+			delete(b.open, child.Ref)
+			child.Incomplete = true
+		}
+		b.closeChildren(child)
+	}
+}
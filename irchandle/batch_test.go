@@ -0,0 +1,112 @@
+package irchandle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+// newBatches returns a Batches handler which records delivered batches and
+// the raw lines passed on, and a function to feed it lines.
+func newBatches(t *testing.T, opts BatchOptions) (batches chan *Batch, passed *[]string, handle func(string)) {
+	batches = make(chan *Batch, 10)
+	passed = new([]string)
+
+	bh := BatchHandlerFunc(func(_ context.Context, _ irc.Encoder, b *Batch) {
+		batches <- b
+	})
+
+	h := Chain(HandlerFunc(func(_ context.Context, _ irc.Encoder, m *irc.Message) {
+		*passed = append(*passed, m.Raw)
+	}), Batches(bh, opts))
+
+	handle = func(line string) {
+		m, err := irc.ParseMessage(line)
+		assert.NoError(t, err)
+		h.HandleMessage(context.Background(), nil, m)
+	}
+
+	return batches, passed, handle
+}
+
+func runBatches(t *testing.T, opts BatchOptions, lines ...string) (batches chan *Batch, passed []string) {
+	batches, p, handle := newBatches(t, opts)
+	for _, line := range lines {
+		handle(line)
+	}
+	return batches, *p
+}
+
+func TestBatches(t *testing.T) {
+	batches, passed := runBatches(t, BatchOptions{},
+		":irc.example.org BATCH +outer labeled-response",
+		"@batch=outer :irc.example.org BATCH +inner chathistory #chan",
+		"@batch=inner :a!a@a PRIVMSG #chan :one",
+		"PING :123",
+		"@batch=inner :b!b@b PRIVMSG #chan :two",
+		"@batch=outer :irc.example.org NOTICE jake :done",
+		":irc.example.org BATCH -inner",
+		"@batch=unknown :c!c@c PRIVMSG #chan :three",
+		":irc.example.org BATCH -outer",
+	)
+
+	assert.Equal(t, []string{"PING :123", "@batch=unknown :c!c@c PRIVMSG #chan :three"}, passed)
+
+	b := <-batches
+	assert.Equal(t, "outer", b.Ref)
+	assert.Equal(t, "labeled-response", b.Type)
+	assert.False(t, b.Incomplete)
+	assert.Len(t, b.Messages, 1)
+	assert.Len(t, b.Batches, 1)
+
+	inner := b.Batches[0]
+	assert.Equal(t, "chathistory", inner.Type)
+	assert.Equal(t, []string{"#chan"}, inner.Params)
+	assert.Equal(t, "one", inner.Messages[0].Trailing)
+	assert.Equal(t, "two", inner.Messages[1].Trailing)
+
+	assert.Len(t, batches, 0)
+}
+
+func TestBatchesTimeout(t *testing.T) {
+	batches, passed, handle := newBatches(t, BatchOptions{Timeout: 10 * time.Millisecond})
+
+	handle(":irc.example.org BATCH +ns netsplit irc.a.org irc.b.org")
+	handle("@batch=ns :a!a@a QUIT :irc.a.org irc.b.org")
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, batches, 0)
+
+	// The batch is delivered before the next message is passed on.
+	handle("PING :123")
+	assert.Equal(t, []string{"PING :123"}, *passed)
+
+	assert.Len(t, batches, 1)
+	b := <-batches
+	assert.True(t, b.Incomplete)
+	assert.Equal(t, []string{"irc.a.org", "irc.b.org"}, b.Params)
+	assert.Len(t, b.Messages, 1)
+
+	// Its end, once it arrives, is ignored.
+	handle(":irc.example.org BATCH -ns")
+	assert.Len(t, batches, 0)
+	assert.Equal(t, []string{"PING :123"}, *passed)
+}
+
+func TestBatchesEndAfterTimeout(t *testing.T) {
+	batches, _, handle := newBatches(t, BatchOptions{Timeout: 10 * time.Millisecond})
+
+	handle(":irc.example.org BATCH +ns netsplit irc.a.org irc.b.org")
+	time.Sleep(20 * time.Millisecond)
+
+	// The batch ends with the first message after the timeout, so is
+	// delivered complete, and only once.
+	handle(":irc.example.org BATCH -ns")
+	handle("PING :123")
+
+	assert.Len(t, batches, 1)
+	assert.False(t, (<-batches).Incomplete)
+}
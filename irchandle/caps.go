@@ -0,0 +1,21 @@
+package irchandle
+
+import (
+	"context"
+
+	"github.com/jakebailey/irc"
+)
+
+// Caps returns a middleware which passes CAP messages to c, so that it
+// tracks capabilities which the server adds or removes after registration.
+// All messages are passed on to the handler.
+func Caps(c *irc.Caps) func(Handler) Handler {
+	return func(handler Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+			// A failure to send a CAP REQ is a failure of the connection,
+			// which will be noticed by the client.
+			c.Handle(e, m) //nolint:errcheck
+			handler.HandleMessage(ctx, e, m)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package irchandle
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jakebailey/irc"
+)
+
+// DefaultChatHistoryLimit is the number of messages requested when a limit
+// of zero is given.
+const DefaultChatHistoryLimit = 50
+
+// HistoryRef is a point in a conversation's history, given by a message ID
+// or a timestamp. The zero HistoryRef means "now", for ChatHistoryLatest.
+type HistoryRef struct {
+	MsgID string
+	Time  time.Time
+}
+
+// MsgIDRef returns a reference to the message with the given ID.
+func MsgIDRef(msgid string) HistoryRef {
+	return HistoryRef{MsgID: msgid}
+}
+
+// TimeRef returns a reference to a time.
+func TimeRef(t time.Time) HistoryRef {
+	return HistoryRef{Time: t}
+}
+
+// MessageRef returns a reference to a message, by its ID if it has one,
+// otherwise by its server-time tag. If it has neither, the message cannot
+// be referred to, and the zero HistoryRef is returned.
+func MessageRef(m *irc.Message) HistoryRef {
+	if id := m.MsgID(); id != "" {
+		return MsgIDRef(id)
+	}
+	if t, ok := m.ServerTime(); ok {
+		return TimeRef(t)
+	}
+	return HistoryRef{}
+}
+
+// IsZero returns true if the reference is the zero HistoryRef.
+func (r HistoryRef) IsZero() bool {
+	return r.MsgID == "" && r.Time.IsZero()
+}
+
+// String returns the reference as a CHATHISTORY parameter.
+func (r HistoryRef) String() string {
+	switch {
+	case r.MsgID != "":
+		return "msgid=" + r.MsgID
+	case !r.Time.IsZero():
+		return "timestamp=" + r.Time.UTC().Format(irc.ServerTimeFormat)
+	default:
+		return "*"
+	}
+}
+
+// HistoryTarget is a conversation listed by ChatHistoryTargets.
+type HistoryTarget struct {
+	Name string
+
+	// Latest is the time of the latest message in the conversation.
+	Latest time.Time
+}
+
+// ChatHistoryLatest requests the latest messages in target's history, after
+// ref if it is not zero. The messages are returned oldest first.
+//
+// The chathistory helpers require the "draft/chathistory" and "batch"
+// capabilities. The Waiter must be in the handler chain (before any Batches
+// middleware), with the Client run with Sync. The messages are not passed
+// on to the handler. If the server rejects the request, the FAIL reply is
+// returned as an *irc.StandardReply. is provides the casemapping used to
+// match the server's reply to target; if nil, the default is used.
+func ChatHistoryLatest(ctx context.Context, e irc.Encoder, w *Waiter, is *irc.ISupport, target string, ref HistoryRef, limit int) ([]*irc.Message, error) {
+	return chatHistory(ctx, e, w, is, target, "LATEST", target, ref.String(), limitParam(limit))
+}
+
+// ChatHistoryBefore requests the messages in target's history before ref.
+func ChatHistoryBefore(ctx context.Context, e irc.Encoder, w *Waiter, is *irc.ISupport, target string, ref HistoryRef, limit int) ([]*irc.Message, error) {
+	return chatHistory(ctx, e, w, is, target, "BEFORE", target, ref.String(), limitParam(limit))
+}
+
+// ChatHistoryAfter requests the messages in target's history after ref.
+func ChatHistoryAfter(ctx context.Context, e irc.Encoder, w *Waiter, is *irc.ISupport, target string, ref HistoryRef, limit int) ([]*irc.Message, error) {
+	return chatHistory(ctx, e, w, is, target, "AFTER", target, ref.String(), limitParam(limit))
+}
+
+// ChatHistoryAround requests the messages in target's history around ref.
+func ChatHistoryAround(ctx context.Context, e irc.Encoder, w *Waiter, is *irc.ISupport, target string, ref HistoryRef, limit int) ([]*irc.Message, error) {
+	return chatHistory(ctx, e, w, is, target, "AROUND", target, ref.String(), limitParam(limit))
+}
+
+// ChatHistoryBetween requests the messages in target's history between
+// start and end.
+func ChatHistoryBetween(ctx context.Context, e irc.Encoder, w *Waiter, is *irc.ISupport, target string, start, end HistoryRef, limit int) ([]*irc.Message, error) {
+	return chatHistory(ctx, e, w, is, target, "BETWEEN", target, start.String(), end.String(), limitParam(limit))
+}
+
+// ChatHistoryTargets requests the conversations which have messages between
+// start and end.
+func ChatHistoryTargets(ctx context.Context, e irc.Encoder, w *Waiter, start, end time.Time, limit int) ([]HistoryTarget, error) {
+	msgs, err := chatHistory(ctx, e, w, nil, "", "TARGETS", TimeRef(start).String(), TimeRef(end).String(), limitParam(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]HistoryTarget, 0, len(msgs))
+	for _, m := range msgs {
+		// CHATHISTORY TARGETS <target> <latest timestamp>
+		params := m.AllParams()
+		if m.Command != "CHATHISTORY" || len(params) < 3 {
+			continue
+		}
+
+		latest, _ := time.Parse(time.RFC3339Nano, strings.TrimPrefix(params[2], "timestamp="))
+		targets = append(targets, HistoryTarget{Name: params[1], Latest: latest})
+	}
+
+	return targets, nil
+}
+
+func limitParam(limit int) string {
+	if limit <= 0 {
+		limit = DefaultChatHistoryLimit
+	}
+	return strconv.Itoa(limit)
+}
+
+// chatHistory sends a CHATHISTORY command, and collects the messages in the
+// batch which answers it. target is empty for TARGETS.
+func chatHistory(ctx context.Context, e irc.Encoder, w *Waiter, is *irc.ISupport, target string, args ...string) ([]*irc.Message, error) {
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+	mapping := is.CaseMapping()
+
+	m := &irc.Message{Command: "CHATHISTORY", Params: args}
+
+	batchType := "chathistory"
+	if target == "" {
+		batchType = "draft/chathistory-targets"
+	}
+
+	var msgs []*irc.Message
+	refs := make(map[string]bool)
+	outer := ""
+
+	err := request(ctx, e, w, m, func(m *irc.Message) (bool, bool) {
+		params := m.AllParams()
+
+		if m.Command == "BATCH" && len(params) > 0 && len(params[0]) > 1 {
+			ref := params[0][1:]
+
+			switch params[0][0] {
+			case '+':
+				if outer == "" {
+					// The batch's parameters start with the target, other
+					// than for TARGETS.
+					if len(params) < 2 || params[1] != batchType ||
+						(target != "" && (len(params) < 3 || mapping.Fold(params[2]) != mapping.Fold(target))) {
+						return false, false
+					}
+
+					outer = ref
+					refs[ref] = true
+					return true, false
+				}
+
+				if refs[m.Batch()] {
+					refs[ref] = true
+					return true, false
+				}
+
+			case '-':
+				if ref == outer {
+					return true, true
+				}
+				if refs[ref] {
+					return true, false
+				}
+			}
+
+			return false, false
+		}
+
+		if outer != "" && refs[m.Batch()] {
+			msgs = append(msgs, m)
+			return true, false
+		}
+
+		return false, false
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return msgs, nil
+}
+
+// HistoryScroller pages backwards through a conversation's history, using
+// CHATHISTORY LATEST and then BEFORE. It is used like a bufio.Scanner:
+//
+//	s := irchandle.NewHistoryScroller(e, w, is, "#channel", irchandle.HistoryRef{}, 100)
+//	for s.Next(ctx) {
+//		page := s.Messages()
+//		...
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+type HistoryScroller struct {
+	e      irc.Encoder
+	w      *Waiter
+	is     *irc.ISupport
+	target string
+	ref    HistoryRef
+	limit  int
+
+	started bool
+	msgs    []*irc.Message
+	err     error
+}
+
+// NewHistoryScroller returns a HistoryScroller for target, starting before
+// ref, or at the latest message if ref is zero. is is used as by
+// ChatHistoryLatest.
+func NewHistoryScroller(e irc.Encoder, w *Waiter, is *irc.ISupport, target string, ref HistoryRef, limit int) *HistoryScroller {
+	return &HistoryScroller{e: e, w: w, is: is, target: target, ref: ref, limit: limit}
+}
+
+// Next fetches the next (older) page of messages, returning false once the
+// start of the history has been reached or an error has occurred. Scrolling
+// also stops after a page whose oldest message has neither a msgid nor a
+// server-time tag, as there is nothing to request the messages before.
+func (s *HistoryScroller) Next(ctx context.Context) bool {
+	if s.err != nil || (s.started && s.ref.IsZero()) {
+		return false
+	}
+
+	var msgs []*irc.Message
+	var err error
+
+	if !s.started && s.ref.IsZero() {
+		msgs, err = ChatHistoryLatest(ctx, s.e, s.w, s.is, s.target, HistoryRef{}, s.limit)
+	} else {
+		msgs, err = ChatHistoryBefore(ctx, s.e, s.w, s.is, s.target, s.ref, s.limit)
+	}
+	s.started = true
+
+	if err != nil {
+		s.err = err
+		return false
+	}
+
+	s.msgs = msgs
+	if len(msgs) == 0 {
+		s.ref = HistoryRef{}
+		return false
+	}
+
+	// If the oldest message cannot be referred to, this is the last page.
+	s.ref = MessageRef(msgs[0])
+	return true
+}
+
+// Messages returns the current page, oldest first.
+func (s *HistoryScroller) Messages() []*irc.Message {
+	return s.msgs
+}
+
+// Err returns the error which stopped the HistoryScroller, if any.
+func (s *HistoryScroller) Err() error {
+	return s.err
+}
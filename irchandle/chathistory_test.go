@@ -0,0 +1,107 @@
+package irchandle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatHistoryBefore(t *testing.T) {
+	w := &Waiter{}
+
+	var msgs []*irc.Message
+	err := serve(t, w, func(e irc.Encoder) (err error) {
+		msgs, err = ChatHistoryBefore(context.Background(), e, w, nil, "#chan[1]", TimeRef(time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)), 0)
+		return err
+	}, func(sent *irc.Message) []string {
+		assert.Equal(t, []string{"BEFORE", "#chan[1]", "timestamp=2020-01-02T03:04:05.006Z", "50"}, sent.Params)
+		return []string{
+			":irc.example.org BATCH +other chathistory #elsewhere",
+			// The rfc1459 casemapping folds [] to {}.
+			":irc.example.org BATCH +h1 chathistory #Chan{1}",
+			"@batch=h1;msgid=1 :a!a@a PRIVMSG #chan :one",
+			"@batch=h1 :irc.example.org BATCH +m1 draft/multiline #chan",
+			"@batch=m1 :b!b@b PRIVMSG #chan :two",
+			":irc.example.org BATCH -m1",
+			":irc.example.org BATCH -h1",
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, "one", msgs[0].Trailing)
+	assert.Equal(t, "two", msgs[1].Trailing)
+}
+
+func TestChatHistoryFail(t *testing.T) {
+	w := &Waiter{}
+
+	err := serve(t, w, func(e irc.Encoder) error {
+		_, err := ChatHistoryLatest(context.Background(), e, w, nil, "#secret", HistoryRef{}, 10)
+		return err
+	}, func(sent *irc.Message) []string {
+		assert.Equal(t, []string{"LATEST", "#secret", "*", "10"}, sent.Params)
+		return []string{":irc.example.org FAIL CHATHISTORY INVALID_TARGET LATEST #secret :Messages could not be retrieved"}
+	})
+
+	var reply *irc.StandardReply
+	assert.True(t, errors.As(err, &reply))
+	assert.Equal(t, "INVALID_TARGET", reply.Code)
+	assert.Equal(t, []string{"LATEST", "#secret"}, reply.Context)
+}
+
+func TestMessageRef(t *testing.T) {
+	m, err := irc.ParseMessage("@msgid=abc;time=2020-01-02T03:04:05.006Z :a!a@a PRIVMSG #chan :hi")
+	assert.NoError(t, err)
+	assert.Equal(t, MsgIDRef("abc"), MessageRef(m))
+
+	delete(m.Tags, "msgid")
+	assert.Equal(t, TimeRef(time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)), MessageRef(m))
+
+	// The time the message was received is no use to the server.
+	m.Received = time.Now()
+	delete(m.Tags, "time")
+	assert.True(t, MessageRef(m).IsZero())
+}
+
+func TestHistoryScroller(t *testing.T) {
+	w := &Waiter{}
+	e := make(chanEncoder, 1)
+	h := w.Middleware(HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) {}))
+
+	pages := [][]string{
+		{"@batch=p;msgid=3 :a!a@a PRIVMSG #chan :three", "@batch=p;msgid=4 :a!a@a PRIVMSG #chan :four"},
+		{"@batch=p;msgid=1 :a!a@a PRIVMSG #chan :one", "@batch=p;msgid=2 :a!a@a PRIVMSG #chan :two"},
+		nil,
+	}
+
+	go func() {
+		for _, page := range pages {
+			sent := <-e
+			if sent == nil {
+				return
+			}
+			lines := append([]string{":irc.example.org BATCH +p chathistory #chan"}, page...)
+			feed(t, h, append(lines, ":irc.example.org BATCH -p")...)
+		}
+	}()
+
+	s := NewHistoryScroller(e, w, nil, "#chan", HistoryRef{}, 2)
+
+	var got []string
+	for s.Next(context.Background()) {
+		var page []string
+		for _, m := range s.Messages() {
+			page = append(page, m.Trailing)
+		}
+		got = append(page, got...)
+	}
+
+	assert.NoError(t, s.Err())
+	assert.Equal(t, []string{"one", "two", "three", "four"}, got)
+	assert.False(t, s.Next(context.Background()))
+}
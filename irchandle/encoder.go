@@ -0,0 +1,18 @@
+package irchandle
+
+import (
+	"context"
+
+	"github.com/jakebailey/irc"
+)
+
+// WithEncoder returns a middleware which replaces the Encoder given to the
+// handler with e. This is used to route replies through a shared outgoing
+// Encoder, such as an irc.Queue or irc.FloodControl.
+func WithEncoder(e irc.Encoder) func(Handler) Handler {
+	return func(handler Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, _ irc.Encoder, m *irc.Message) {
+			handler.HandleMessage(ctx, e, m)
+		})
+	}
+}
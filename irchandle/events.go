@@ -0,0 +1,112 @@
+package irchandle
+
+import (
+	"context"
+
+	"github.com/jakebailey/irc"
+	"github.com/jakebailey/irc/events"
+)
+
+// The On methods register typed handlers, which are passed the decoded
+// event. Messages which cannot be decoded (for example, because they have
+// too few parameters) are dropped. Like Handle, a command may have several
+// handlers.
+
+// OnPrivmsg registers f to handle PRIVMSG messages.
+func (mux *Mux) OnPrivmsg(f func(ctx context.Context, e irc.Encoder, ev *events.Privmsg)) {
+	on(mux, "PRIVMSG", events.DecodePrivmsg, f)
+}
+
+// OnNotice registers f to handle NOTICE messages.
+func (mux *Mux) OnNotice(f func(ctx context.Context, e irc.Encoder, ev *events.Notice)) {
+	on(mux, "NOTICE", events.DecodeNotice, f)
+}
+
+// OnTagmsg registers f to handle TAGMSG messages.
+func (mux *Mux) OnTagmsg(f func(ctx context.Context, e irc.Encoder, ev *events.Tagmsg)) {
+	on(mux, "TAGMSG", events.DecodeTagmsg, f)
+}
+
+// OnJoin registers f to handle JOIN messages.
+func (mux *Mux) OnJoin(f func(ctx context.Context, e irc.Encoder, ev *events.Join)) {
+	on(mux, "JOIN", events.DecodeJoin, f)
+}
+
+// OnPart registers f to handle PART messages.
+func (mux *Mux) OnPart(f func(ctx context.Context, e irc.Encoder, ev *events.Part)) {
+	on(mux, "PART", events.DecodePart, f)
+}
+
+// OnKick registers f to handle KICK messages.
+func (mux *Mux) OnKick(f func(ctx context.Context, e irc.Encoder, ev *events.Kick)) {
+	on(mux, "KICK", events.DecodeKick, f)
+}
+
+// OnMode registers f to handle MODE messages.
+func (mux *Mux) OnMode(f func(ctx context.Context, e irc.Encoder, ev *events.Mode)) {
+	on(mux, "MODE", events.DecodeMode, f)
+}
+
+// OnTopic registers f to handle TOPIC messages.
+func (mux *Mux) OnTopic(f func(ctx context.Context, e irc.Encoder, ev *events.Topic)) {
+	on(mux, "TOPIC", events.DecodeTopic, f)
+}
+
+// OnInvite registers f to handle INVITE messages.
+func (mux *Mux) OnInvite(f func(ctx context.Context, e irc.Encoder, ev *events.Invite)) {
+	on(mux, "INVITE", events.DecodeInvite, f)
+}
+
+// OnQuit registers f to handle QUIT messages.
+func (mux *Mux) OnQuit(f func(ctx context.Context, e irc.Encoder, ev *events.Quit)) {
+	on(mux, "QUIT", events.DecodeQuit, f)
+}
+
+// OnNick registers f to handle NICK messages.
+func (mux *Mux) OnNick(f func(ctx context.Context, e irc.Encoder, ev *events.Nick)) {
+	on(mux, "NICK", events.DecodeNick, f)
+}
+
+// OnAway registers f to handle AWAY messages.
+func (mux *Mux) OnAway(f func(ctx context.Context, e irc.Encoder, ev *events.Away)) {
+	on(mux, "AWAY", events.DecodeAway, f)
+}
+
+// OnAccount registers f to handle ACCOUNT messages.
+func (mux *Mux) OnAccount(f func(ctx context.Context, e irc.Encoder, ev *events.Account)) {
+	on(mux, "ACCOUNT", events.DecodeAccount, f)
+}
+
+// OnChghost registers f to handle CHGHOST messages.
+func (mux *Mux) OnChghost(f func(ctx context.Context, e irc.Encoder, ev *events.Chghost)) {
+	on(mux, "CHGHOST", events.DecodeChghost, f)
+}
+
+// OnSetname registers f to handle SETNAME messages.
+func (mux *Mux) OnSetname(f func(ctx context.Context, e irc.Encoder, ev *events.Setname)) {
+	on(mux, "SETNAME", events.DecodeSetname, f)
+}
+
+// OnPing registers f to handle PING messages.
+func (mux *Mux) OnPing(f func(ctx context.Context, e irc.Encoder, ev *events.Ping)) {
+	on(mux, "PING", events.DecodePing, f)
+}
+
+// OnPong registers f to handle PONG messages.
+func (mux *Mux) OnPong(f func(ctx context.Context, e irc.Encoder, ev *events.Pong)) {
+	on(mux, "PONG", events.DecodePong, f)
+}
+
+// OnError registers f to handle ERROR messages.
+func (mux *Mux) OnError(f func(ctx context.Context, e irc.Encoder, ev *events.Error)) {
+	on(mux, "ERROR", events.DecodeError, f)
+}
+
+// on registers f to handle command, decoding each message with decode.
+func on[T any](mux *Mux, command string, decode func(*irc.Message) (T, error), f func(ctx context.Context, e irc.Encoder, ev T)) {
+	mux.HandleFunc(command, func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+		if ev, err := decode(m); err == nil {
+			f(ctx, e, ev)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package irchandle
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jakebailey/irc"
+	"github.com/jakebailey/irc/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMuxTypedHandlers(t *testing.T) {
+	mux := NewMux()
+
+	var privmsgs []*events.Privmsg
+	mux.OnPrivmsg(func(_ context.Context, _ irc.Encoder, ev *events.Privmsg) {
+		privmsgs = append(privmsgs, ev)
+	})
+
+	var kicked []string
+	mux.OnKick(func(_ context.Context, _ irc.Encoder, ev *events.Kick) {
+		kicked = append(kicked, ev.Nick)
+	})
+
+	feed(t, mux,
+		":alice!a@a.org PRIVMSG #chan :hello",
+		":alice!a@a.org PRIVMSG #chan",
+		":alice!a@a.org KICK #chan bob",
+		":alice!a@a.org NOTICE #chan :ignored",
+	)
+
+	assert.Len(t, privmsgs, 1)
+	assert.Equal(t, "hello", privmsgs[0].Text)
+	assert.Equal(t, "alice", privmsgs[0].Prefix.Name)
+	assert.Equal(t, []string{"bob"}, kicked)
+
+	var second int
+	mux.OnPrivmsg(func(context.Context, irc.Encoder, *events.Privmsg) { second++ })
+	feed(t, mux, ":alice!a@a.org PRIVMSG #chan :again")
+	assert.Len(t, privmsgs, 2)
+	assert.Equal(t, 1, second)
+}
@@ -0,0 +1,19 @@
+package irchandle
+
+import (
+	"context"
+
+	"github.com/jakebailey/irc"
+)
+
+// Identity returns a middleware which passes messages to id, so that it
+// tracks changes to the client's nick, host and account. All messages are
+// passed on to the handler.
+func Identity(id *irc.Identity) func(Handler) Handler {
+	return func(handler Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+			id.Handle(m)
+			handler.HandleMessage(ctx, e, m)
+		})
+	}
+}
@@ -0,0 +1,509 @@
+package irchandle
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebailey/irc"
+)
+
+// maxJoinLineLen is the maximum length of the channels and keys in a single
+// JOIN command.
+const maxJoinLineLen = 400
+
+// JoinChannel is a channel to join, and its key, if it has one.
+type JoinChannel struct {
+	Name string
+	Key  string
+}
+
+// JoinResult is the outcome of joining a channel.
+type JoinResult struct {
+	Channel string
+
+	// Err is nil if the channel was joined. Otherwise, it is the server's
+	// error, usually an *irc.NumericError (for example, one wrapping
+	// irc.ErrBannedFromChan or irc.ErrBadChannelKey), or the error which
+	// ended the request.
+	Err error
+}
+
+// JoinOptions configures Join.
+type JoinOptions struct {
+	// ISupport provides the server's TARGMAX limit and casemapping. If nil,
+	// there is no limit, and the default casemapping is used.
+	ISupport *irc.ISupport
+}
+
+// joinErrors are the error numerics which refuse to join the channel they
+// are about.
+var joinErrors = map[string]bool{
+	irc.ERR_NOSUCHCHANNEL:   true,
+	irc.ERR_TOOMANYCHANNELS: true,
+	irc.ERR_CHANNELISFULL:   true,
+	irc.ERR_INVITEONLYCHAN:  true,
+	irc.ERR_BANNEDFROMCHAN:  true,
+	irc.ERR_BADCHANNELKEY:   true,
+	irc.ERR_BADCHANMASK:     true,
+}
+
+type pendingJoin struct {
+	indexes []int
+	joiners map[string]bool
+}
+
+// Join joins channels, sending as few JOIN commands as the line length and
+// the server's TARGMAX allow, then waits until each channel has been joined
+// (the client's JOIN, followed by RPL_ENDOFNAMES) or refused. A result is
+// returned for each channel, in order. If the context ends, or the server
+// rejects the command with FAIL or ERR_NEEDMOREPARAMS, that error is
+// returned, and is the result of every channel which was still pending.
+//
+// The replies are passed on to the handler, so that trackers such as State
+// see them. The Waiter must be in the handler chain, with the Client run
+// with Sync.
+func Join(ctx context.Context, e irc.Encoder, w *Waiter, channels []JoinChannel, opts JoinOptions) ([]JoinResult, error) {
+	is := opts.ISupport
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+	fold := is.CaseMapping().Fold
+
+	results := make([]JoinResult, len(channels))
+	pending := make(map[string]*pendingJoin, len(channels))
+	var unique []JoinChannel
+
+	for i, c := range channels {
+		results[i].Channel = c.Name
+
+		key := fold(c.Name)
+		p := pending[key]
+		if p == nil {
+			p = &pendingJoin{joiners: make(map[string]bool)}
+			pending[key] = p
+			unique = append(unique, c)
+		}
+		p.indexes = append(p.indexes, i)
+	}
+
+	if len(unique) == 0 {
+		return results, nil
+	}
+
+	msgs := joinMessages(unique, is.TargMax("JOIN"))
+
+	var rejected error
+
+	err := requestAll(ctx, e, w, msgs, func(m *irc.Message) (bool, bool) {
+		params := m.AllParams()
+
+		switch m.Command {
+		case "JOIN":
+			// Others may join at the same time, so the joiners are checked
+			// against the client's nick once RPL_ENDOFNAMES arrives.
+			if len(params) > 0 {
+				if p := pending[fold(params[0])]; p != nil {
+					p.joiners[fold(m.Prefix.Name)] = true
+				}
+			}
+
+		case irc.RPL_ENDOFNAMES:
+			// <client> <channel> :End of /NAMES list
+			if len(params) > 1 {
+				key := fold(params[1])
+				if p := pending[key]; p != nil && p.joiners[fold(params[0])] {
+					delete(pending, key)
+				}
+			}
+
+		case irc.ERR_NEEDMOREPARAMS:
+			// <client> <command> :Not enough parameters
+			if numErr, _ := irc.ParseNumericError(m); strings.EqualFold(numErr.Target, "JOIN") {
+				rejected = numErr
+				return false, true
+			}
+
+		default:
+			if !joinErrors[m.Command] {
+				break
+			}
+
+			// <client> <channel> :<text>
+			numErr, _ := irc.ParseNumericError(m)
+			key := fold(numErr.Target)
+			if p := pending[key]; p != nil {
+				for _, i := range p.indexes {
+					results[i].Err = numErr
+				}
+				delete(pending, key)
+			}
+		}
+
+		return false, len(pending) == 0
+	})
+
+	if err == nil {
+		err = rejected
+	}
+
+	if err != nil {
+		for _, p := range pending {
+			for _, i := range p.indexes {
+				results[i].Err = err
+			}
+		}
+		return results, err
+	}
+
+	return results, nil
+}
+
+// joinMessages builds JOIN commands for channels, each within
+// maxJoinLineLen and with at most targMax channels, if it is not zero.
+func joinMessages(channels []JoinChannel, targMax int) []*irc.Message {
+	// Keys are matched to channels by position, so the channels with keys
+	// must come first.
+	sorted := make([]JoinChannel, 0, len(channels))
+	for _, c := range channels {
+		if c.Key != "" {
+			sorted = append(sorted, c)
+		}
+	}
+	for _, c := range channels {
+		if c.Key == "" {
+			sorted = append(sorted, c)
+		}
+	}
+
+	var msgs []*irc.Message
+	var names, keys []string
+	length := 0
+
+	flush := func() {
+		if len(names) == 0 {
+			return
+		}
+
+		m := &irc.Message{Command: "JOIN", Params: []string{strings.Join(names, ",")}}
+		if len(keys) > 0 {
+			m.Params = append(m.Params, strings.Join(keys, ","))
+		}
+
+		msgs = append(msgs, m)
+		names, keys = nil, nil
+		length = 0
+	}
+
+	for _, c := range sorted {
+		n := len(c.Name) + len(c.Key) + 2
+		if length+n > maxJoinLineLen || (targMax > 0 && len(names) >= targMax) {
+			flush()
+		}
+
+		names = append(names, c.Name)
+		if c.Key != "" {
+			keys = append(keys, c.Key)
+		}
+		length += n
+	}
+	flush()
+
+	return msgs
+}
+
+// Defaults for AutoJoinOptions.
+const (
+	DefaultRejoinBackoff    = 5 * time.Second
+	DefaultMaxRejoinBackoff = 5 * time.Minute
+)
+
+// AutoJoinOptions configures an AutoJoin.
+type AutoJoinOptions struct {
+	// Backoff is the delay before rejoining a channel after being kicked
+	// from it. It doubles with each further kick, up to MaxBackoff, and
+	// resets once MaxBackoff has passed without a kick. If zero,
+	// DefaultRejoinBackoff is used.
+	Backoff time.Duration
+
+	// MaxBackoff is the longest delay before rejoining. If zero,
+	// DefaultMaxRejoinBackoff is used.
+	MaxBackoff time.Duration
+}
+
+// AutoJoin keeps the client in a set of channels. The channels are joined
+// when it is started, rejoined after a backoff when the client is kicked,
+// and rejoined when the client is invited to one it is not in. Parting a
+// channel does not remove it from the set; use Remove. It is safe for
+// concurrent use.
+//
+// Call Start once registered, and after each reconnection. Use Middleware
+// in the handler chain, which passes every message on to the handler.
+type AutoJoin struct {
+	opts AutoJoinOptions
+
+	mu       sync.Mutex
+	e        irc.Encoder
+	is       *irc.ISupport
+	nick     string
+	channels map[string]*autoJoinChannel
+}
+
+type autoJoinChannel struct {
+	JoinChannel
+
+	joined   bool
+	kicks    int
+	lastKick time.Time
+	timer    *time.Timer
+}
+
+// NewAutoJoin returns a new AutoJoin.
+func NewAutoJoin(opts AutoJoinOptions) *AutoJoin {
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultRejoinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxRejoinBackoff
+	}
+
+	return &AutoJoin{
+		opts:     opts,
+		is:       &irc.ISupport{},
+		channels: make(map[string]*autoJoinChannel),
+	}
+}
+
+// Start joins every channel in the set on a newly registered connection,
+// sending to e. The connection's nick is taken from w, and then followed
+// through NICK messages, and its ISUPPORT tokens provide the TARGMAX limit
+// and casemapping.
+func (a *AutoJoin) Start(e irc.Encoder, w *irc.Welcome) error {
+	a.mu.Lock()
+
+	is := w.ISupport
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+
+	a.e = e
+	a.is = is
+	a.nick = w.Nick
+
+	channels := make(map[string]*autoJoinChannel, len(a.channels))
+	for _, c := range a.channels {
+		c.stop()
+		c.joined = false
+		c.kicks = 0
+		channels[a.fold(c.Name)] = c
+	}
+	a.channels = channels
+
+	msgs := joinMessages(a.list(), is.TargMax("JOIN"))
+
+	a.mu.Unlock()
+
+	return send(e, msgs)
+}
+
+// Close stops any pending rejoins, and stops joining channels until the
+// AutoJoin is started again.
+func (a *AutoJoin) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, c := range a.channels {
+		c.stop()
+	}
+	a.e = nil
+}
+
+// Add adds channels to the set, joining them if the AutoJoin has been
+// started. Adding a channel which is already in the set updates its key.
+func (a *AutoJoin) Add(channels ...JoinChannel) error {
+	a.mu.Lock()
+
+	var added []JoinChannel
+	for _, ch := range channels {
+		key := a.fold(ch.Name)
+		c := a.channels[key]
+		if c == nil {
+			c = &autoJoinChannel{}
+			a.channels[key] = c
+		}
+		c.JoinChannel = ch
+
+		if !c.joined {
+			added = append(added, ch)
+		}
+	}
+
+	var msgs []*irc.Message
+	if a.e != nil {
+		msgs = joinMessages(added, a.is.TargMax("JOIN"))
+	}
+	e := a.e
+
+	a.mu.Unlock()
+
+	return send(e, msgs)
+}
+
+// Remove removes channels from the set. The channels are not parted.
+func (a *AutoJoin) Remove(names ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, name := range names {
+		key := a.fold(name)
+		if c := a.channels[key]; c != nil {
+			c.stop()
+			delete(a.channels, key)
+		}
+	}
+}
+
+// Channels returns the channels in the set, sorted by name.
+func (a *AutoJoin) Channels() []JoinChannel {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.list()
+}
+
+// Middleware returns the AutoJoin as a middleware.
+func (a *AutoJoin) Middleware(handler Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+		a.handle(m)
+		handler.HandleMessage(ctx, e, m)
+	})
+}
+
+func (a *AutoJoin) handle(m *irc.Message) {
+	params := m.AllParams()
+	var msgs []*irc.Message
+
+	a.mu.Lock()
+
+	self := func(name string) bool {
+		return a.nick != "" && a.fold(name) == a.fold(a.nick)
+	}
+
+	switch m.Command {
+	case "NICK":
+		if len(params) > 0 && self(m.Prefix.Name) {
+			a.nick = params[0]
+		}
+
+	case "JOIN":
+		if len(params) > 0 && self(m.Prefix.Name) {
+			if c := a.channels[a.fold(params[0])]; c != nil {
+				c.stop()
+				c.joined = true
+			}
+		}
+
+	case "PART":
+		if len(params) > 0 && self(m.Prefix.Name) {
+			if c := a.channels[a.fold(params[0])]; c != nil {
+				c.joined = false
+			}
+		}
+
+	case "KICK":
+		// KICK <channel> <nick> [:<reason>]
+		if len(params) > 1 && self(params[1]) {
+			if c := a.channels[a.fold(params[0])]; c != nil {
+				c.joined = false
+				a.scheduleRejoin(c)
+			}
+		}
+
+	case "INVITE":
+		// INVITE <nick> <channel>, which with invite-notify may be sent
+		// for others.
+		if len(params) > 1 && self(params[0]) && a.e != nil {
+			if c := a.channels[a.fold(params[1])]; c != nil && !c.joined {
+				c.stop()
+				msgs = joinMessages([]JoinChannel{c.JoinChannel}, 0)
+			}
+		}
+	}
+
+	e := a.e
+
+	a.mu.Unlock()
+
+	// A failure to send is a failure of the connection, after which Start
+	// is called again.
+	send(e, msgs) //nolint:errcheck
+}
+
+// scheduleRejoin rejoins c after the backoff. a.mu must be held.
+func (a *AutoJoin) scheduleRejoin(c *autoJoinChannel) {
+	now := time.Now()
+	if now.Sub(c.lastKick) > a.opts.MaxBackoff {
+		c.kicks = 0
+	}
+	c.lastKick = now
+
+	delay := a.opts.Backoff
+	for i := 0; i < c.kicks && delay < a.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > a.opts.MaxBackoff {
+		delay = a.opts.MaxBackoff
+	}
+	c.kicks++
+
+	c.stop()
+	c.timer = time.AfterFunc(delay, func() {
+		a.mu.Lock()
+
+		var msgs []*irc.Message
+		if a.channels[a.fold(c.Name)] == c && !c.joined && a.e != nil {
+			msgs = joinMessages([]JoinChannel{c.JoinChannel}, 0)
+		}
+		e := a.e
+
+		a.mu.Unlock()
+
+		send(e, msgs) //nolint:errcheck
+	})
+}
+
+// list returns the channels in the set, sorted by name. a.mu must be held.
+func (a *AutoJoin) list() []JoinChannel {
+	channels := make([]JoinChannel, 0, len(a.channels))
+	for _, c := range a.channels {
+		channels = append(channels, c.JoinChannel)
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+
+	return channels
+}
+
+func (a *AutoJoin) fold(name string) string {
+	return a.is.CaseMapping().Fold(name)
+}
+
+func (c *autoJoinChannel) stop() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+}
+
+func send(e irc.Encoder, msgs []*irc.Message) error {
+	for _, m := range msgs {
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package irchandle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoin(t *testing.T) {
+	w := &Waiter{}
+
+	var results []JoinResult
+	err := serve(t, w, func(e irc.Encoder) (err error) {
+		channels := []JoinChannel{{Name: "#a"}, {Name: "#b"}, {Name: "#c", Key: "secret"}, {Name: "#A"}}
+		results, err = Join(context.Background(), e, w, channels, JoinOptions{})
+		return err
+	}, func(sent *irc.Message) []string {
+		assert.Equal(t, []string{"#c,#a,#b", "secret"}, sent.Params)
+		return []string{
+			":me!u@h JOIN #a",
+			":irc.example.org 353 me = #a :me alice",
+			":other!u@h JOIN #b",
+			":irc.example.org 366 me #a :End of /NAMES list.",
+			// Only errors about joining count.
+			":irc.example.org 404 me #b :Cannot send to channel",
+			":irc.example.org 474 me #b :Cannot join channel (+b)",
+			":irc.example.org 475 me #C :Cannot join channel (+k)",
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, results, 4)
+	assert.Equal(t, JoinResult{Channel: "#a"}, results[0])
+	assert.True(t, errors.Is(results[1].Err, irc.ErrBannedFromChan))
+	assert.True(t, errors.Is(results[2].Err, irc.ErrBadChannelKey))
+	assert.Equal(t, JoinResult{Channel: "#A"}, results[3])
+}
+
+func TestJoinNeedMoreParams(t *testing.T) {
+	w := &Waiter{}
+
+	var results []JoinResult
+	err := serve(t, w, func(e irc.Encoder) (err error) {
+		results, err = Join(context.Background(), e, w, []JoinChannel{{Name: "#a"}, {Name: "#b"}}, JoinOptions{})
+		return err
+	}, func(sent *irc.Message) []string {
+		return []string{
+			":irc.example.org 461 me MODE :Not enough parameters",
+			":irc.example.org 461 me JOIN :Not enough parameters",
+		}
+	})
+	assert.True(t, errors.Is(err, irc.ErrNeedMoreParams))
+
+	assert.Len(t, results, 2)
+	for _, r := range results {
+		assert.Equal(t, err, r.Err)
+	}
+}
+
+func TestJoinMessages(t *testing.T) {
+	var channels []JoinChannel
+	for i := 0; i < 50; i++ {
+		channels = append(channels, JoinChannel{Name: "#channel" + strings.Repeat("x", i%5)})
+	}
+	channels[10].Key = "key"
+
+	msgs := joinMessages(channels, 20)
+	assert.Len(t, msgs, 3)
+	assert.Equal(t, "key", msgs[0].Params[1])
+	assert.True(t, strings.HasPrefix(msgs[0].Params[0], "#channel,"))
+	assert.Len(t, msgs[1].Params, 1)
+
+	msgs = joinMessages(channels, 0)
+	assert.Len(t, msgs, 2)
+	for _, m := range msgs {
+		assert.LessOrEqual(t, len(m.String()), len("JOIN ")+maxJoinLineLen)
+	}
+}
+
+func TestAutoJoin(t *testing.T) {
+	a := NewAutoJoin(AutoJoinOptions{Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	defer a.Close()
+
+	e := &syncEncoder{}
+	assert.NoError(t, a.Add(JoinChannel{Name: "#b"}, JoinChannel{Name: "#a", Key: "k"}))
+	assert.Empty(t, e.messages())
+
+	var passed int
+	h := a.Middleware(HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) { passed++ }))
+
+	// Register has consumed RPL_WELCOME, so the nick comes from Start.
+	assert.NoError(t, a.Start(e, &irc.Welcome{Nick: "me", ISupport: &irc.ISupport{}}))
+	assert.Equal(t, []string{"JOIN #a,#b k"}, e.messages())
+
+	feed(t, h,
+		":me!u@h JOIN #a",
+		":me!u@h JOIN #B",
+		":me!u@h NICK me2",
+		":op!u@h KICK #a me2 :Go away",
+		":op!u@h KICK #b someone :Not you",
+	)
+	assert.Equal(t, 5, passed)
+
+	assert.Eventually(t, func() bool { return len(e.messages()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, "JOIN #a k", e.messages()[1])
+
+	feed(t, h,
+		":op!u@h INVITE me2 #a",
+		":op!u@h INVITE someone #b",
+		":op!u@h INVITE me2 #elsewhere",
+	)
+	assert.Equal(t, "JOIN #a k", e.messages()[2])
+	assert.Len(t, e.messages(), 3)
+
+	a.Remove("#A")
+	assert.Equal(t, []JoinChannel{{Name: "#b"}}, a.Channels())
+
+	// A new connection starts again with its own nick.
+	e = &syncEncoder{}
+	assert.NoError(t, a.Start(e, &irc.Welcome{Nick: "other"}))
+	assert.Equal(t, []string{"JOIN #b"}, e.messages())
+
+	feed(t, h, ":op!u@h INVITE me2 #b")
+	assert.Len(t, e.messages(), 1)
+
+	feed(t, h, ":op!u@h INVITE other #b")
+	assert.Equal(t, "JOIN #b", e.messages()[1])
+}
@@ -0,0 +1,151 @@
+package irchandle
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jakebailey/irc"
+)
+
+var labelCounter uint64
+
+// LabeledCall is a command sent with a label, which completes once the
+// server has responded (using the labeled-response capability) or the
+// context given to SendLabeled ends.
+type LabeledCall struct {
+	// Label is the label the command was sent with.
+	Label string
+
+	done     chan struct{}
+	once     sync.Once
+	messages []*irc.Message
+	err      error
+}
+
+// Done returns a channel which is closed when the call completes.
+func (c *LabeledCall) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait waits for the call to complete, then returns the responses. A single
+// reply is returned alone. For a labeled-response batch, the messages within
+// it are returned (including those in nested batches, and the BATCH
+// messages of nested batches), but not the BATCH messages of the batch
+// itself. An ACK returns no messages. If the responses include a FAIL
+// standard reply or an error numeric, it is returned as the error (as an
+// *irc.StandardReply or *irc.NumericError) along with the messages. If the
+// context ended first, its error is returned.
+func (c *LabeledCall) Wait() ([]*irc.Message, error) {
+	<-c.done
+	return c.messages, c.err
+}
+
+func (c *LabeledCall) complete(messages []*irc.Message, err error) {
+	if err == nil {
+		for _, m := range messages {
+			if r, ok := irc.ParseStandardReply(m); ok && r.Fail() {
+				err = r
+				break
+			}
+			if numErr, ok := irc.ParseNumericError(m); ok {
+				err = numErr
+				break
+			}
+		}
+	}
+
+	c.once.Do(func() {
+		c.messages = messages
+		c.err = err
+		close(c.done)
+	})
+}
+
+// SendLabeled sends m through e with a new label, which is set in m's tags,
+// and returns a call which completes with the server's responses. The
+// "labeled-response" capability must be enabled, and the Waiter must be in
+// the handler chain, with the Client run with Sync. If consume is true, the
+// responses are not passed on to the handler.
+func SendLabeled(ctx context.Context, e irc.Encoder, w *Waiter, m *irc.Message, consume bool) *LabeledCall {
+	c := &LabeledCall{
+		Label: strconv.FormatUint(atomic.AddUint64(&labelCounter, 1), 36),
+		done:  make(chan struct{}),
+	}
+
+	if m.Tags == nil {
+		m.Tags = make(map[string]string)
+	}
+	m.Tags["label"] = c.Label
+
+	var mu sync.Mutex
+	var messages []*irc.Message
+	refs := make(map[string]bool)
+	var outer string
+
+	cancel := w.Listen(func(m *irc.Message) (bool, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if ref := m.Batch(); ref != "" && refs[ref] {
+			if m.Command == "BATCH" && len(m.Params) > 0 && len(m.Params[0]) > 1 && m.Params[0][0] == '+' {
+				refs[m.Params[0][1:]] = true
+			}
+			messages = append(messages, m)
+			return consume, false
+		}
+
+		if outer != "" {
+			// The end of the labeled-response batch.
+			if m.Command == "BATCH" && len(m.Params) > 0 && m.Params[0] == "-"+outer {
+				c.complete(messages, nil)
+				return consume, true
+			}
+
+			// The ends of nested batches are not tagged.
+			if m.Command == "BATCH" && len(m.Params) > 0 && len(m.Params[0]) > 1 && refs[m.Params[0][1:]] {
+				messages = append(messages, m)
+				return consume, false
+			}
+
+			return false, false
+		}
+
+		if m.Label() != c.Label {
+			return false, false
+		}
+
+		switch {
+		case m.Command == "ACK":
+			c.complete(nil, nil)
+			return consume, true
+
+		case m.Command == "BATCH" && len(m.Params) > 0 && len(m.Params[0]) > 1 && m.Params[0][0] == '+':
+			outer = m.Params[0][1:]
+			refs[outer] = true
+			return consume, false
+
+		default:
+			c.complete([]*irc.Message{m}, nil)
+			return consume, true
+		}
+	})
+
+	if err := e.Encode(m); err != nil {
+		cancel()
+		c.complete(nil, err)
+		return c
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+			c.complete(nil, ctx.Err())
+		case <-c.done:
+		}
+	}()
+
+	return c
+}
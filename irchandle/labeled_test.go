@@ -0,0 +1,100 @@
+package irchandle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingEncoder struct {
+	messages []*irc.Message
+}
+
+func (r *recordingEncoder) Encode(m *irc.Message) error {
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func feed(t *testing.T, h Handler, lines ...string) {
+	for _, line := range lines {
+		m, err := irc.ParseMessage(line)
+		assert.NoError(t, err)
+		h.HandleMessage(context.Background(), nil, m)
+	}
+}
+
+func TestSendLabeled(t *testing.T) {
+	w := &Waiter{}
+	e := &recordingEncoder{}
+
+	var passed []string
+	h := Chain(HandlerFunc(func(_ context.Context, _ irc.Encoder, m *irc.Message) {
+		passed = append(passed, m.Command)
+	}), w.Middleware)
+
+	c := SendLabeled(context.Background(), e, w, &irc.Message{Command: "WHOIS", Params: []string{"jake"}}, true)
+	label := e.messages[0].Tags["label"]
+	assert.Equal(t, c.Label, label)
+
+	feed(t, h,
+		"PING :1",
+		"@label="+label+" :irc.example.org BATCH +b1 labeled-response",
+		"@batch=b1 :irc.example.org 311 me jake ~jake example.org * :Jake",
+		"@batch=b1 :irc.example.org 318 me jake :End of /WHOIS list.",
+		":irc.example.org BATCH -b1",
+		"PING :2",
+	)
+
+	msgs, err := c.Wait()
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, "311", msgs[0].Command)
+	assert.Equal(t, []string{"PING", "PING"}, passed)
+
+	c = SendLabeled(context.Background(), e, w, &irc.Message{Command: "MODE", Params: []string{"#chan", "+i"}}, false)
+	feed(t, h, "@label="+c.Label+" :irc.example.org ACK")
+
+	msgs, err = c.Wait()
+	assert.NoError(t, err)
+	assert.Empty(t, msgs)
+	assert.Equal(t, []string{"PING", "PING", "ACK"}, passed)
+}
+
+func TestSendLabeledFail(t *testing.T) {
+	w := &Waiter{}
+	e := &recordingEncoder{}
+	h := w.Middleware(HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) {}))
+
+	c := SendLabeled(context.Background(), e, w, &irc.Message{Command: "REGISTER", Params: []string{"*", "*", "hunter2"}}, true)
+	feed(t, h, "@label="+c.Label+" :irc.example.org FAIL REGISTER TEMPORARILY_UNAVAILABLE * :Try again later")
+
+	msgs, err := c.Wait()
+	assert.Len(t, msgs, 1)
+
+	var reply *irc.StandardReply
+	assert.True(t, errors.As(err, &reply))
+	assert.Equal(t, "TEMPORARILY_UNAVAILABLE", reply.Code)
+
+	c = SendLabeled(context.Background(), e, w, &irc.Message{Command: "PRIVMSG", Params: []string{"#chan"}, Trailing: "hi"}, true)
+	feed(t, h, "@label="+c.Label+" :irc.example.org 404 me #chan :Cannot send to channel")
+
+	_, err = c.Wait()
+	assert.True(t, errors.Is(err, irc.ErrCannotSendToChan))
+}
+
+func TestSendLabeledTimeout(t *testing.T) {
+	w := &Waiter{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	c := SendLabeled(ctx, &recordingEncoder{}, w, &irc.Message{Command: "PING", Params: []string{"x"}}, true)
+
+	_, err := c.Wait()
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Empty(t, w.listeners)
+}
@@ -0,0 +1,304 @@
+package irchandle
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebailey/irc"
+)
+
+// DefaultListBuffer is the number of LIST entries buffered when
+// ListOptions.Buffer is zero.
+const DefaultListBuffer = 100
+
+// ListEntry is a channel listed by LIST.
+type ListEntry struct {
+	Channel string
+	Users   int
+
+	// Modes are the channel's modes, which some servers show before the
+	// topic, for example "+nt".
+	Modes string
+
+	Topic string
+}
+
+// ListOptions configures List. The filters are sent to the server when it
+// supports them, according to its ELIST token. Otherwise, the mask and user
+// count filters are applied as the entries arrive, and the other filters
+// are ignored.
+type ListOptions struct {
+	// ISupport is used to check which filters the server supports, and
+	// provides its casemapping. If nil, the only filter sent is a Mask
+	// without wildcards, which every server supports.
+	ISupport *irc.ISupport
+
+	// Mask, if set, limits the list to channels matching a mask, such as
+	// "#go*". A mask with wildcards is only sent if ELIST includes M.
+	Mask string
+
+	// MinUsers and MaxUsers, if not zero, limit the list to channels with
+	// at least or at most that many users.
+	MinUsers int
+	MaxUsers int
+
+	// MinAge and MaxAge, if not zero, limit the list to channels created at
+	// least or at most that long ago. The server's precision is a minute.
+	MinAge time.Duration
+	MaxAge time.Duration
+
+	// TopicMinAge and TopicMaxAge, if not zero, limit the list to channels
+	// whose topic was set at least or at most that long ago.
+	TopicMinAge time.Duration
+	TopicMaxAge time.Duration
+
+	// Buffer is the number of entries which may arrive before they are
+	// read. If zero, DefaultListBuffer is used.
+	Buffer int
+}
+
+// ListIterator reads the entries of a LIST reply as they arrive. It is
+// used like a bufio.Scanner:
+//
+//	it, err := irchandle.List(ctx, e, w, irchandle.ListOptions{MinUsers: 10})
+//	if err != nil {
+//		...
+//	}
+//	defer it.Close()
+//
+//	for it.Next() {
+//		entry := it.Entry()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ListIterator struct {
+	ctx     context.Context
+	entries chan *ListEntry
+	stop    chan struct{}
+	once    sync.Once
+	cancel  func()
+
+	mu    sync.Mutex
+	err   error
+	entry *ListEntry
+}
+
+// List sends LIST, returning an iterator over the entries, which ends at
+// RPL_LISTEND, when the context ends, or when the iterator is closed. The
+// replies are not passed on to the handler until the iterator ends.
+//
+// Once the buffer is full, the Waiter blocks until an entry is read, which
+// stalls the handler chain and, in turn, reading from the connection. This
+// keeps a large list out of memory, but entries must be read promptly
+// enough that the connection does not time out. The Waiter must be in the
+// handler chain, with the Client run with Sync.
+func List(ctx context.Context, e irc.Encoder, w *Waiter, opts ListOptions) (*ListIterator, error) {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultListBuffer
+	}
+
+	args, filter := listParams(opts)
+
+	it := &ListIterator{
+		ctx:     ctx,
+		entries: make(chan *ListEntry, opts.Buffer),
+		stop:    make(chan struct{}),
+	}
+
+	it.cancel = w.Listen(func(m *irc.Message) (bool, bool) {
+		params := m.AllParams()
+
+		switch m.Command {
+		case irc.RPL_LISTSTART:
+			return true, false
+
+		case irc.RPL_LIST:
+			// <client> <channel> <users> :[[<modes>] ]<topic>
+			if len(params) < 3 {
+				return true, false
+			}
+
+			entry := &ListEntry{Channel: params[1], Topic: params[len(params)-1]}
+			entry.Users, _ = strconv.Atoi(params[2])
+
+			if strings.HasPrefix(entry.Topic, "[+") {
+				if i := strings.Index(entry.Topic, "]"); i != -1 {
+					entry.Modes = entry.Topic[1:i]
+					entry.Topic = strings.TrimPrefix(entry.Topic[i+1:], " ")
+				}
+			}
+
+			if !filter(entry) {
+				return true, false
+			}
+
+			select {
+			case it.entries <- entry:
+				return true, false
+			case <-it.stop:
+				return false, true
+			case <-ctx.Done():
+				return false, true
+			}
+
+		case irc.RPL_LISTEND:
+			close(it.entries)
+			return true, true
+		}
+
+		if r, ok := irc.ParseStandardReply(m); ok && r.Fail() && r.Command == "LIST" {
+			it.setErr(r)
+			close(it.entries)
+			return true, true
+		}
+
+		if numErr, ok := irc.ParseNumericError(m); ok && strings.EqualFold(numErr.Target, "LIST") {
+			it.setErr(numErr)
+			close(it.entries)
+			return true, true
+		}
+
+		return false, false
+	})
+
+	m := &irc.Message{Command: "LIST", Params: args}
+	if err := e.Encode(m); err != nil {
+		it.Close()
+		return nil, err
+	}
+
+	return it, nil
+}
+
+// listParams returns the parameters for LIST, and a filter for the options
+// which the server does not support.
+func listParams(opts ListOptions) ([]string, func(*ListEntry) bool) {
+	is := opts.ISupport
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+
+	elist, _ := is.Get("ELIST")
+	elist = strings.ToUpper(elist)
+	supports := func(c byte) bool {
+		return strings.IndexByte(elist, c) != -1
+	}
+
+	var conds []string
+
+	// Any server can list channels by name, but only those with ELIST M
+	// accept wildcards.
+	mask := opts.Mask
+	if mask != "" && (supports('M') || !strings.ContainsAny(mask, "*?")) {
+		conds = append(conds, mask)
+		mask = ""
+	}
+
+	minUsers, maxUsers := opts.MinUsers, opts.MaxUsers
+	if supports('U') {
+		if minUsers > 0 {
+			conds = append(conds, ">"+strconv.Itoa(minUsers-1))
+		}
+		if maxUsers > 0 {
+			conds = append(conds, "<"+strconv.Itoa(maxUsers+1))
+		}
+		minUsers, maxUsers = 0, 0
+	}
+
+	if supports('C') {
+		conds = appendAgeConds(conds, "C", opts.MinAge, opts.MaxAge)
+	}
+	if supports('T') {
+		conds = appendAgeConds(conds, "T", opts.TopicMinAge, opts.TopicMaxAge)
+	}
+
+	cm := is.CaseMapping()
+	mask = cm.Fold(mask)
+
+	filter := func(entry *ListEntry) bool {
+		if mask != "" && !matchMask(mask, cm.Fold(entry.Channel)) {
+			return false
+		}
+		return (minUsers <= 0 || entry.Users >= minUsers) && (maxUsers <= 0 || entry.Users <= maxUsers)
+	}
+
+	if len(conds) == 0 {
+		return nil, filter
+	}
+	return []string{strings.Join(conds, ",")}, filter
+}
+
+// appendAgeConds appends ELIST conditions for ages, which are given in
+// minutes: "C>n" for older than n minutes, and "C<n" for newer.
+func appendAgeConds(conds []string, kind string, minAge, maxAge time.Duration) []string {
+	if minAge > 0 {
+		conds = append(conds, kind+">"+strconv.Itoa(int(minAge/time.Minute)))
+	}
+	if maxAge > 0 {
+		conds = append(conds, kind+"<"+strconv.Itoa(int((maxAge+time.Minute-1)/time.Minute)))
+	}
+	return conds
+}
+
+// Next waits for the next entry, returning false once the list has ended,
+// the context has ended, or the iterator has been closed.
+func (it *ListIterator) Next() bool {
+	select {
+	case <-it.stop:
+		return false
+	default:
+	}
+
+	select {
+	case entry, ok := <-it.entries:
+		if !ok {
+			it.Close()
+			return false
+		}
+		it.entry = entry
+		return true
+	case <-it.ctx.Done():
+		it.setErr(it.ctx.Err())
+		it.Close()
+		return false
+	case <-it.stop:
+		return false
+	}
+}
+
+// Entry returns the current entry.
+func (it *ListIterator) Entry() *ListEntry {
+	return it.entry
+}
+
+// Err returns the error which ended the iterator, if any. Closing the
+// iterator early is not an error.
+func (it *ListIterator) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	return it.err
+}
+
+// Close stops the iterator. Any further replies are passed on to the
+// handler.
+func (it *ListIterator) Close() {
+	it.once.Do(func() {
+		close(it.stop)
+		it.cancel()
+	})
+}
+
+func (it *ListIterator) setErr(err error) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.err == nil {
+		it.err = err
+	}
+}
@@ -0,0 +1,124 @@
+package irchandle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	w := &Waiter{}
+	e := make(chanEncoder, 1)
+	h := w.Middleware(HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) {}))
+
+	is := &irc.ISupport{}
+	is.Update(&irc.Message{Command: irc.RPL_ISUPPORT, Params: []string{"me", "ELIST=CMNTU"}})
+
+	it, err := List(context.Background(), e, w, ListOptions{ISupport: is, Mask: "#go*", MinUsers: 5, MaxAge: 90 * time.Minute, Buffer: 1})
+	assert.NoError(t, err)
+	defer it.Close()
+	assert.Equal(t, []string{"#go*,>4,C<90"}, (<-e).Params)
+
+	fed := make(chan struct{})
+	go func() {
+		feed(t, h,
+			":irc.example.org 321 me Channel :Users Name",
+			":irc.example.org 322 me #go 120 :[+nt] The Go programming language",
+			":irc.example.org 322 me #go-nuts 40 :",
+			":irc.example.org 322 me #gopher 7 :Gophers",
+			":irc.example.org 323 me :End of /LIST",
+		)
+		close(fed)
+	}()
+
+	// With a buffer of one, the entries are held back until read.
+	select {
+	case <-fed:
+		t.Fatal("list was not held back")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	var entries []*ListEntry
+	for it.Next() {
+		entries = append(entries, it.Entry())
+	}
+	assert.NoError(t, it.Err())
+	<-fed
+
+	assert.Equal(t, []*ListEntry{
+		{Channel: "#go", Users: 120, Modes: "+nt", Topic: "The Go programming language"},
+		{Channel: "#go-nuts", Users: 40},
+		{Channel: "#gopher", Users: 7, Topic: "Gophers"},
+	}, entries)
+}
+
+func TestListLocalFilter(t *testing.T) {
+	w := &Waiter{}
+	e := make(chanEncoder, 1)
+	h := w.Middleware(HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) {}))
+
+	it, err := List(context.Background(), e, w, ListOptions{Mask: "#m*", MinUsers: 5, MaxUsers: 50, TopicMaxAge: time.Hour})
+	assert.NoError(t, err)
+	assert.Empty(t, (<-e).Params)
+
+	feed(t, h,
+		":irc.example.org 322 me #big 120 :",
+		":irc.example.org 322 me #medium 40 :",
+		":irc.example.org 322 me #other 40 :",
+		":irc.example.org 322 me #small 2 :",
+		":irc.example.org 323 me :End of /LIST",
+	)
+
+	assert.True(t, it.Next())
+	assert.Equal(t, "#medium", it.Entry().Channel)
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+
+	// Every server can list a channel by name.
+	args, _ := listParams(ListOptions{Mask: "#go"})
+	assert.Equal(t, []string{"#go"}, args)
+}
+
+func TestListCancel(t *testing.T) {
+	w := &Waiter{}
+	var passed []string
+	h := w.Middleware(HandlerFunc(func(_ context.Context, _ irc.Encoder, m *irc.Message) {
+		passed = append(passed, m.Command)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	it, err := List(ctx, &recordingEncoder{}, w, ListOptions{})
+	assert.NoError(t, err)
+
+	feed(t, h, ":irc.example.org 322 me #a 1 :")
+	cancel()
+
+	// The buffered entry may or may not be read before the cancellation.
+	for it.Next() {
+	}
+	assert.Equal(t, context.Canceled, it.Err())
+
+	feed(t, h, ":irc.example.org 323 me :End of /LIST")
+	assert.Equal(t, []string{irc.RPL_LISTEND}, passed)
+	assert.Empty(t, w.listeners)
+}
+
+func TestListFail(t *testing.T) {
+	w := &Waiter{}
+	h := w.Middleware(HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) {}))
+
+	it, err := List(context.Background(), &recordingEncoder{}, w, ListOptions{})
+	assert.NoError(t, err)
+
+	feed(t, h, ":irc.example.org 416 me LIST :Output too large, truncated")
+
+	assert.False(t, it.Next())
+
+	numErr, ok := it.Err().(*irc.NumericError)
+	assert.True(t, ok)
+	assert.Equal(t, irc.ERR_TOOMANYMATCHES, numErr.Code)
+}
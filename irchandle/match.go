@@ -0,0 +1,223 @@
+package irchandle
+
+import (
+	"strings"
+
+	"github.com/jakebailey/irc"
+)
+
+// Matcher reports whether a Route handles a message.
+type Matcher func(m *irc.Message) bool
+
+// Commands matches messages with any of the given commands.
+func Commands(commands ...string) Matcher {
+	return func(m *irc.Message) bool {
+		for _, command := range commands {
+			if m.Command == command {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NumericRange matches numeric replies from min to max inclusive, for
+// example 400 to 599 for errors.
+func NumericRange(min, max int) Matcher {
+	return func(m *irc.Message) bool {
+		n, ok := numeric(m.Command)
+		return ok && n >= min && n <= max
+	}
+}
+
+func numeric(command string) (int, bool) {
+	if len(command) != 3 {
+		return 0, false
+	}
+
+	n := 0
+	for i := 0; i < 3; i++ {
+		c := command[i]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+
+	return n, true
+}
+
+// Target matches messages whose target is one of targets. The target is
+// the first parameter of PRIVMSG, NOTICE, TAGMSG, JOIN, PART, KICK, MODE,
+// TOPIC and INVITE (for which it is the invited nick); other messages have
+// none. Targets are compared using the casemapping from is, or the default
+// if is is nil.
+func Target(is *irc.ISupport, targets ...string) Matcher {
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+
+	return func(m *irc.Message) bool {
+		target, ok := messageTarget(m)
+		if !ok {
+			return false
+		}
+
+		cm := is.CaseMapping()
+		for _, t := range targets {
+			if cm.Equal(t, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// InChannel matches messages whose target is a channel, according to
+// CHANTYPES from is, including messages to only the channel's members with
+// a given prefix (such as "@#channel"), according to STATUSMSG. If is is
+// nil, the defaults are used.
+func InChannel(is *irc.ISupport) Matcher {
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+
+	return func(m *irc.Message) bool {
+		target, ok := messageTarget(m)
+		if !ok {
+			return false
+		}
+
+		statusMsg, _ := is.Get("STATUSMSG")
+		target = strings.TrimLeft(target, statusMsg)
+
+		return is.IsChannel(target)
+	}
+}
+
+// InPrivate matches messages with a target which is not a channel, such as
+// a PRIVMSG sent directly to the client.
+func InPrivate(is *irc.ISupport) Matcher {
+	inChannel := InChannel(is)
+	return func(m *irc.Message) bool {
+		_, ok := messageTarget(m)
+		return ok && !inChannel(m)
+	}
+}
+
+// SenderMask matches messages whose source matches any of masks, such as
+// "*!*@example.org", where "*" matches any number of characters and "?"
+// matches one. Masks are compared using the casemapping from is, or the
+// default if is is nil.
+func SenderMask(is *irc.ISupport, masks ...string) Matcher {
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+
+	return func(m *irc.Message) bool {
+		if m.Prefix.Name == "" {
+			return false
+		}
+
+		source := m.Prefix.Name
+		if m.Prefix.User != "" || m.Prefix.Host != "" {
+			source += "!" + m.Prefix.User + "@" + m.Prefix.Host
+		}
+
+		cm := is.CaseMapping()
+		source = cm.Fold(source)
+
+		for _, mask := range masks {
+			mask = cm.Fold(mask)
+			if matchMask(mask, source) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasTag matches messages with the tag.
+func HasTag(key irc.TagKey) Matcher {
+	return func(m *irc.Message) bool {
+		_, ok := m.Tag(key)
+		return ok
+	}
+}
+
+// All matches messages which all of matchers match.
+func All(matchers ...Matcher) Matcher {
+	return func(m *irc.Message) bool {
+		for _, match := range matchers {
+			if !match(m) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any matches messages which any of matchers match.
+func Any(matchers ...Matcher) Matcher {
+	return func(m *irc.Message) bool {
+		for _, match := range matchers {
+			if match(m) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches messages which match does not.
+func Not(match Matcher) Matcher {
+	return func(m *irc.Message) bool {
+		return !match(m)
+	}
+}
+
+// messageTarget returns the target of a message, which is the first
+// parameter of the commands which have one.
+func messageTarget(m *irc.Message) (string, bool) {
+	switch m.Command {
+	case "PRIVMSG", "NOTICE", "TAGMSG", "JOIN", "PART", "KICK", "MODE", "TOPIC", "INVITE":
+	default:
+		return "", false
+	}
+
+	// JOIN's channel is sometimes sent as the trailing parameter.
+	params := m.AllParams()
+	if len(params) == 0 {
+		return "", false
+	}
+	return params[0], true
+}
+
+// matchMask matches s against a mask containing the wildcards "*" and "?".
+func matchMask(mask, s string) bool {
+	// The position to resume from when a "*" must match more.
+	star, next := -1, 0
+
+	i, j := 0, 0
+	for j < len(s) {
+		switch {
+		case i < len(mask) && mask[i] == '*':
+			star, next = i, j
+			i++
+		case i < len(mask) && (mask[i] == '?' || mask[i] == s[j]):
+			i++
+			j++
+		case star != -1:
+			next++
+			i, j = star+1, next
+		default:
+			return false
+		}
+	}
+
+	for i < len(mask) && mask[i] == '*' {
+		i++
+	}
+
+	return i == len(mask)
+}
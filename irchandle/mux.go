@@ -2,48 +2,163 @@ package irchandle
 
 import (
 	"context"
+	"sort"
 
 	"github.com/jakebailey/irc"
 )
 
+// Route is a handler registered with a Mux, and the messages it handles.
+type Route struct {
+	// Command, if set, limits the route to messages with that command.
+	Command string
+
+	// Match, if set, limits the route to messages for which it returns
+	// true.
+	Match Matcher
+
+	// Priority orders the routes which handle a message. Higher priorities
+	// run first, and routes of equal priority run in the order they were
+	// added.
+	Priority int
+
+	// Middleware wraps Handler for this route only, as by Chain.
+	Middleware []func(Handler) Handler
+
+	Handler Handler
+}
+
+type muxRoute struct {
+	match    Matcher
+	priority int
+	seq      int
+	handler  Handler
+}
+
+// before returns true if r runs before other.
+func (r *muxRoute) before(other *muxRoute) bool {
+	if r.priority != other.priority {
+		return r.priority > other.priority
+	}
+	return r.seq < other.seq
+}
+
+// Mux routes messages to handlers. Every route which matches a message
+// handles it, in priority order; if none match, the NotFound handler does.
+// Observers see every message, before the routes. Routes must be added
+// before the Mux is used.
 type Mux struct {
-	m        map[string]Handler
-	notFound Handler
+	commands  map[string][]*muxRoute
+	wild      []*muxRoute
+	observers []Handler
+	notFound  Handler
+	seq       int
 }
 
+// NewMux returns a new Mux.
 func NewMux() *Mux {
 	return &Mux{
-		m: make(map[string]Handler),
+		commands: make(map[string][]*muxRoute),
 	}
 }
 
 var _ Handler = (*Mux)(nil)
 
+// Add adds a route.
+func (mux *Mux) Add(r Route) {
+	if r.Handler == nil {
+		panic("irc: nil handler")
+	}
+
+	route := &muxRoute{
+		match:    r.Match,
+		priority: r.Priority,
+		seq:      mux.seq,
+		handler:  Chain(r.Handler, r.Middleware...),
+	}
+	mux.seq++
+
+	if r.Command != "" {
+		mux.commands[r.Command] = insertRoute(mux.commands[r.Command], route)
+	} else {
+		mux.wild = insertRoute(mux.wild, route)
+	}
+}
+
+// insertRoute inserts r after the routes with the same or a higher
+// priority.
+func insertRoute(routes []*muxRoute, r *muxRoute) []*muxRoute {
+	i := sort.Search(len(routes), func(i int) bool {
+		return routes[i].priority < r.priority
+	})
+
+	routes = append(routes, nil)
+	copy(routes[i+1:], routes[i:])
+	routes[i] = r
+
+	return routes
+}
+
+// Handle adds a route for a command. A command may have several handlers,
+// which run in the order they were added. The command must not be empty;
+// to handle every message, use Add with no Command.
 func (mux *Mux) Handle(command string, handler Handler) {
-	if _, ok := mux.m[command]; ok {
-		panic("irc: multiple registrations for " + command)
+	if command == "" {
+		panic("irc: empty command")
 	}
-	mux.m[command] = handler
+	mux.Add(Route{Command: command, Handler: handler})
 }
 
+// HandleFunc adds a route for a command to a handler function.
 func (mux *Mux) HandleFunc(command string, handler func(context.Context, irc.Encoder, *irc.Message)) {
-	if _, ok := mux.m[command]; ok {
-		panic("irc: multiple registrations for " + command)
-	}
-	mux.m[command] = HandlerFunc(handler)
+	mux.Handle(command, HandlerFunc(handler))
+}
+
+// Observe adds a handler which sees every message, in addition to the
+// routes which handle it. Observers do not count as handling a message, so
+// the NotFound handler still runs if no route matches.
+func (mux *Mux) Observe(handler Handler) {
+	mux.observers = append(mux.observers, handler)
+}
+
+// ObserveFunc adds a handler function as an observer.
+func (mux *Mux) ObserveFunc(handler func(context.Context, irc.Encoder, *irc.Message)) {
+	mux.Observe(HandlerFunc(handler))
 }
 
+// HandleMessage passes m to the observers, then to every matching route.
 func (mux *Mux) HandleMessage(ctx context.Context, e irc.Encoder, m *irc.Message) {
-	handler, ok := mux.m[m.Command]
-	if !ok {
-		handler = mux.notFound
+	for _, h := range mux.observers {
+		h.HandleMessage(ctx, e, m)
+	}
+
+	// Merge the command's routes with those for any command, keeping
+	// their order.
+	commands := mux.commands[m.Command]
+	wild := mux.wild
+	handled := false
+
+	for len(commands) > 0 || len(wild) > 0 {
+		var r *muxRoute
+		if len(wild) == 0 || (len(commands) > 0 && commands[0].before(wild[0])) {
+			r, commands = commands[0], commands[1:]
+		} else {
+			r, wild = wild[0], wild[1:]
+		}
+
+		if r.match != nil && !r.match(m) {
+			continue
+		}
+
+		handled = true
+		r.handler.HandleMessage(ctx, e, m)
 	}
 
-	if handler != nil {
-		handler.HandleMessage(ctx, e, m)
+	if !handled && mux.notFound != nil {
+		mux.notFound.HandleMessage(ctx, e, m)
 	}
 }
 
+// NotFound sets the handler for messages which no route matches.
 func (mux *Mux) NotFound(handler Handler) {
 	mux.notFound = handler
 }
@@ -0,0 +1,146 @@
+package irchandle
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMux(t *testing.T) {
+	mux := NewMux()
+
+	var calls []string
+	record := func(name string) Handler {
+		return HandlerFunc(func(_ context.Context, _ irc.Encoder, m *irc.Message) {
+			calls = append(calls, name+":"+m.Command)
+		})
+	}
+
+	mux.Handle("PRIVMSG", record("first"))
+	mux.Handle("PRIVMSG", record("second"))
+	mux.Add(Route{Command: "PRIVMSG", Priority: 10, Handler: record("urgent")})
+	mux.Add(Route{Match: NumericRange(400, 599), Handler: record("errors")})
+	mux.Add(Route{Match: Commands("PRIVMSG", "NOTICE"), Priority: 5, Handler: record("messages")})
+	mux.Observe(record("observer"))
+	mux.NotFound(record("notfound"))
+
+	feed(t, mux,
+		":alice!a@a.org PRIVMSG #chan :hi",
+		":irc.example.org 474 me #chan :Cannot join channel (+b)",
+		":irc.example.org 001 me :Welcome",
+	)
+
+	assert.Equal(t, []string{
+		"observer:PRIVMSG", "urgent:PRIVMSG", "messages:PRIVMSG", "first:PRIVMSG", "second:PRIVMSG",
+		"observer:474", "errors:474",
+		"observer:001", "notfound:001",
+	}, calls)
+}
+
+func TestMuxMiddleware(t *testing.T) {
+	mux := NewMux()
+
+	var calls []string
+	wrap := func(name string) func(Handler) Handler {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+				calls = append(calls, name)
+				next.HandleMessage(ctx, e, m)
+			})
+		}
+	}
+
+	mux.Add(Route{
+		Command:    "PRIVMSG",
+		Middleware: []func(Handler) Handler{wrap("outer"), wrap("inner")},
+		Handler: HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) {
+			calls = append(calls, "handler")
+		}),
+	})
+	mux.HandleFunc("PRIVMSG", func(context.Context, irc.Encoder, *irc.Message) {
+		calls = append(calls, "plain")
+	})
+
+	feed(t, mux, ":alice!a@a.org PRIVMSG #chan :hi")
+	assert.Equal(t, []string{"outer", "inner", "handler", "plain"}, calls)
+}
+
+func TestMuxHandleEmptyCommand(t *testing.T) {
+	mux := NewMux()
+	assert.Panics(t, func() {
+		mux.HandleFunc("", func(context.Context, irc.Encoder, *irc.Message) {})
+	})
+}
+
+func TestMatchers(t *testing.T) {
+	is := &irc.ISupport{}
+	is.Update(&irc.Message{Command: irc.RPL_ISUPPORT, Params: []string{"me", "STATUSMSG=@+"}})
+
+	ascii := &irc.ISupport{}
+	ascii.Update(&irc.Message{Command: irc.RPL_ISUPPORT, Params: []string{"me", "CASEMAPPING=ascii"}})
+
+	tests := []struct {
+		line  string
+		match Matcher
+		want  bool
+	}{
+		{":alice!a@a.org PRIVMSG #chan :hi", InChannel(is), true},
+		{":alice!a@a.org PRIVMSG @#chan :hi", InChannel(is), true},
+		{":alice!a@a.org PRIVMSG me :hi", InChannel(is), false},
+		{":alice!a@a.org PRIVMSG me :hi", InPrivate(nil), true},
+		{":alice!a@a.org PRIVMSG #chan :hi", InPrivate(nil), false},
+		{"PING", InPrivate(nil), false},
+		{":alice!a@a.org QUIT :#chan", InChannel(is), false},
+		{":alice!a@a.org NICK :#chan", InPrivate(nil), false},
+		{":alice!a@a.org PRIVMSG #Chan[] :hi", Target(nil, "#other", "#chan{}"), true},
+		{":alice!a@a.org PRIVMSG #Chan[] :hi", Target(ascii, "#chan{}"), false},
+		{":alice!a@a.org PRIVMSG #Chan[] :hi", Target(ascii, "#chan[]"), true},
+		{":alice!a@a.org PRIVMSG #chan :hi", Target(nil, "#other"), false},
+		{":alice!a@a.org JOIN :#chan", Target(nil, "#chan"), true},
+		{":alice!a@a.org QUIT :#chan", Target(nil, "#chan"), false},
+		{":alice!a@a.org NICK #chan", Target(nil, "#chan"), false},
+		{":Alice!a@A.org PRIVMSG #chan :hi", SenderMask(nil, "*!*@a.org"), true},
+		{":alice!a@a.org PRIVMSG #chan :hi", SenderMask(nil, "bob!*@*", "al?ce!*"), true},
+		{":alice!a@evil.org PRIVMSG #chan :hi", SenderMask(nil, "*!*@a.org"), false},
+		{":irc.example.org NOTICE * :hi", SenderMask(nil, "*.example.org"), true},
+		{":al[ice!a@a.org PRIVMSG #chan :hi", SenderMask(nil, "al{ice!*"), true},
+		{":al[ice!a@a.org PRIVMSG #chan :hi", SenderMask(ascii, "al{ice!*"), false},
+		{"@+typing=active :alice!a@a.org TAGMSG #chan", HasTag("+typing"), true},
+		{":alice!a@a.org TAGMSG #chan", HasTag("+typing"), false},
+		{":irc.example.org 404 me #chan :Cannot send", NumericRange(400, 599), true},
+		{":irc.example.org 366 me #chan :End", NumericRange(400, 599), false},
+		{":alice!a@a.org PRIVMSG #chan :hi", All(InChannel(nil), Not(SenderMask(nil, "bob!*@*"))), true},
+		{":alice!a@a.org PRIVMSG #chan :hi", Any(InPrivate(nil), HasTag("bot")), false},
+	}
+
+	for _, test := range tests {
+		m, err := irc.ParseMessage(test.line)
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, test.match(m), test.line)
+	}
+}
+
+func TestMatchMask(t *testing.T) {
+	tests := []struct {
+		mask, s string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"*!*@*.org", "nick!user@host.org", true},
+		{"*a*a", "banana", true},
+		{"*a*a", "bananas", false},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, matchMask(test.mask, test.s), test.mask+" "+test.s)
+	}
+}
@@ -0,0 +1,61 @@
+package irchandle
+
+import (
+	"context"
+
+	"github.com/jakebailey/irc"
+)
+
+// NamesOptions configures Names.
+type NamesOptions struct {
+	// ISupport provides the server's PREFIX and casemapping. If nil, the
+	// defaults are used.
+	ISupport *irc.ISupport
+}
+
+// Names sends NAMES for channel, then collects the members from each
+// RPL_NAMREPLY until RPL_ENDOFNAMES. If the channel does not exist (or is
+// secret), the server sends no members, so none are returned and the
+// type is zero.
+//
+// The replies are passed on to the handler, so that trackers such as State
+// see them. The Waiter must be in the handler chain, with the Client run
+// with Sync.
+func Names(ctx context.Context, e irc.Encoder, w *Waiter, channel string, opts NamesOptions) (*irc.Names, error) {
+	is := opts.ISupport
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+	fold := is.CaseMapping().Fold
+
+	names := &irc.Names{Channel: channel}
+
+	m := &irc.Message{Command: "NAMES", Params: []string{channel}}
+
+	err := request(ctx, e, w, m, func(m *irc.Message) (bool, bool) {
+		switch m.Command {
+		case irc.RPL_NAMREPLY:
+			reply, ok := irc.ParseNames(m, is)
+			if ok && fold(reply.Channel) == fold(channel) {
+				names.Channel = reply.Channel
+				names.Type = reply.Type
+				names.Members = append(names.Members, reply.Members...)
+			}
+
+		case irc.RPL_ENDOFNAMES:
+			// <client> <channel> :End of /NAMES list
+			params := m.AllParams()
+			if len(params) > 1 && fold(params[1]) == fold(channel) {
+				return false, true
+			}
+		}
+
+		return false, false
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
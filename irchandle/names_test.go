@@ -0,0 +1,39 @@
+package irchandle
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNames(t *testing.T) {
+	w := &Waiter{}
+
+	var names *irc.Names
+	err := serve(t, w, func(e irc.Encoder) (err error) {
+		names, err = Names(context.Background(), e, w, "#chan", NamesOptions{})
+		return err
+	}, func(sent *irc.Message) []string {
+		assert.Equal(t, []string{"#chan"}, sent.Params)
+		return []string{
+			":irc.example.org 353 me = #Chan :@+alice!a@a.org bob",
+			":irc.example.org 353 me = #other :carol",
+			":irc.example.org 353 me = #Chan :+dave",
+			":irc.example.org 366 me #other :End of /NAMES list.",
+			":irc.example.org 366 me #CHAN :End of /NAMES list.",
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, &irc.Names{
+		Channel: "#Chan",
+		Type:    irc.ChannelPublic,
+		Members: []irc.NamesMember{
+			{Nick: "alice", User: "a", Host: "a.org", Prefixes: "@+", Modes: "ov"},
+			{Nick: "bob"},
+			{Nick: "dave", Prefixes: "+", Modes: "v"},
+		},
+	}, names)
+}
@@ -0,0 +1,567 @@
+package irchandle
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebailey/irc"
+)
+
+// DefaultPresencePollInterval is the interval between ISON polls when
+// PresenceOptions.PollInterval is zero.
+const DefaultPresencePollInterval = time.Minute
+
+// maxPresenceLineLen is the maximum length of the nicks in a single
+// MONITOR, WATCH or ISON command.
+const maxPresenceLineLen = 400
+
+// PresenceEvent reports a change in whether a watched nick is online.
+type PresenceEvent struct {
+	Nick   string
+	Online bool
+
+	// Prefix is the user's prefix, if the server sent it. Its Name is the
+	// nick with the server's capitalization.
+	Prefix irc.Prefix
+
+	// ListFull is true if the server refused to watch the nick because its
+	// MONITOR or WATCH list is full, in which case Online is meaningless.
+	// The nick is polled with ISON instead.
+	ListFull bool
+}
+
+// PresenceOptions configures a Presence.
+type PresenceOptions struct {
+	// PollInterval is the interval between ISON polls, which are used when
+	// the server supports neither MONITOR nor WATCH, or for nicks beyond
+	// the server's limit. If zero, DefaultPresencePollInterval is used.
+	PollInterval time.Duration
+
+	// OnChange, if set, is called when any watched nick comes online or
+	// goes offline, or the server's list is too full to watch it.
+	OnChange func(PresenceEvent)
+}
+
+type presenceMode int
+
+const (
+	presenceISON presenceMode = iota
+	presenceMonitor
+	presenceWatch
+)
+
+// Presence tracks whether a set of nicks are online, using MONITOR if the
+// server supports it, then WATCH, then polling with ISON. Nicks beyond the
+// server's MONITOR or WATCH limit are polled with ISON. It is safe for
+// concurrent use.
+//
+// Call Start once registered, and after each reconnection, which
+// registers the whole list with the server again. Use Middleware in the
+// handler chain to process the server's replies, which are not passed on.
+// Presence never lists the server's MONITOR list, so RPL_MONLIST and
+// RPL_ENDOFMONLIST are passed on for whoever sent MONITOR L.
+type Presence struct {
+	opts PresenceOptions
+
+	mu         sync.Mutex
+	e          irc.Encoder
+	is         *irc.ISupport
+	mode       presenceMode
+	limit      int
+	nicks      map[string]string
+	order      []string
+	registered map[string]bool
+	online     map[string]bool
+	subs       map[string][]*presenceSub
+	ison       [][]string
+	stop       chan struct{}
+}
+
+type presenceSub struct {
+	nick string
+	f    func(PresenceEvent)
+}
+
+// NewPresence returns a new Presence.
+func NewPresence(opts PresenceOptions) *Presence {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPresencePollInterval
+	}
+
+	return &Presence{
+		opts:       opts,
+		is:         &irc.ISupport{},
+		nicks:      make(map[string]string),
+		registered: make(map[string]bool),
+		online:     make(map[string]bool),
+		subs:       make(map[string][]*presenceSub),
+	}
+}
+
+// Start begins tracking on a newly registered connection, sending to e,
+// using the server's ISUPPORT tokens to choose a method. If is is nil, the
+// default ISupport is used, so nicks are polled with ISON. Every nick which
+// has been added is registered with the server.
+func (p *Presence) Start(e irc.Encoder, is *irc.ISupport) error {
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+
+	p.mu.Lock()
+
+	if p.stop != nil {
+		close(p.stop)
+	}
+
+	p.e = e
+	p.setISupport(is)
+	p.registered = make(map[string]bool)
+	p.ison = nil
+	p.stop = make(chan struct{})
+
+	p.mode = presenceISON
+	p.limit = 0
+
+	if is.Has("MONITOR") {
+		p.mode = presenceMonitor
+		p.limit, _ = is.Int("MONITOR")
+	} else if is.Has("WATCH") {
+		p.mode = presenceWatch
+		p.limit, _ = is.Int("WATCH")
+	}
+
+	msgs := p.register(p.list())
+	stop := p.stop
+
+	p.mu.Unlock()
+
+	// Polling starts once the registrations are sent, so that the first
+	// poll does not ask about nicks which are about to be registered.
+	err := send(e, msgs)
+	go p.poll(stop)
+
+	return err
+}
+
+// list returns the watched nicks, in the order they were added. p.mu must
+// be held.
+func (p *Presence) list() []string {
+	nicks := make([]string, 0, len(p.order))
+	for _, key := range p.order {
+		nicks = append(nicks, p.nicks[key])
+	}
+	return nicks
+}
+
+// setISupport replaces the ISUPPORT tokens, refolding the nicks in case the
+// casemapping has changed. p.mu must be held.
+func (p *Presence) setISupport(is *irc.ISupport) {
+	p.is = is
+
+	nicks := make(map[string]string, len(p.nicks))
+	order := make([]string, 0, len(p.order))
+	online := make(map[string]bool, len(p.online))
+	for _, key := range p.order {
+		nick := p.nicks[key]
+		newKey := p.fold(nick)
+		if _, ok := nicks[newKey]; ok {
+			continue
+		}
+
+		nicks[newKey] = nick
+		order = append(order, newKey)
+		if v, ok := p.online[key]; ok {
+			online[newKey] = v
+		}
+	}
+
+	subs := make(map[string][]*presenceSub, len(p.subs))
+	for _, list := range p.subs {
+		for _, sub := range list {
+			key := p.fold(sub.nick)
+			subs[key] = append(subs[key], sub)
+		}
+	}
+
+	p.nicks = nicks
+	p.order = order
+	p.online = online
+	p.subs = subs
+}
+
+// Close stops polling. The Presence may be started again.
+func (p *Presence) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+}
+
+// Add starts watching nicks.
+func (p *Presence) Add(nicks ...string) error {
+	p.mu.Lock()
+
+	var added []string
+	for _, nick := range nicks {
+		key := p.fold(nick)
+		if _, ok := p.nicks[key]; !ok {
+			p.nicks[key] = nick
+			p.order = append(p.order, key)
+			added = append(added, nick)
+		}
+	}
+
+	var msgs []*irc.Message
+	if p.e != nil {
+		msgs = p.register(added)
+	}
+	e := p.e
+
+	p.mu.Unlock()
+
+	return send(e, msgs)
+}
+
+// Remove stops watching nicks.
+func (p *Presence) Remove(nicks ...string) error {
+	p.mu.Lock()
+
+	var removed []string
+	for _, nick := range nicks {
+		key := p.fold(nick)
+		if _, ok := p.nicks[key]; !ok {
+			continue
+		}
+
+		delete(p.nicks, key)
+		delete(p.online, key)
+
+		for i, other := range p.order {
+			if other == key {
+				p.order = append(p.order[:i], p.order[i+1:]...)
+				break
+			}
+		}
+
+		if p.registered[key] {
+			delete(p.registered, key)
+			removed = append(removed, nick)
+		}
+	}
+
+	var msgs []*irc.Message
+	switch p.mode {
+	case presenceMonitor:
+		msgs = batchNicks("MONITOR", "-", ",", removed)
+	case presenceWatch:
+		msgs = batchNicks("WATCH", "-", " ", removed)
+	}
+	e := p.e
+
+	p.mu.Unlock()
+
+	return send(e, msgs)
+}
+
+// Online returns whether a nick is online, and whether that is known.
+func (p *Presence) Online(nick string) (online, known bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	online, known = p.online[p.fold(nick)]
+	return online, known
+}
+
+// Subscribe calls f whenever nick comes online or goes offline, or the
+// server's list is too full to watch it, until the returned function is
+// called. Subscribing does not add the nick.
+func (p *Presence) Subscribe(nick string, f func(PresenceEvent)) (cancel func()) {
+	sub := &presenceSub{nick: nick, f: f}
+
+	p.mu.Lock()
+	key := p.fold(nick)
+	p.subs[key] = append(p.subs[key], sub)
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		subs := p.subs[key]
+		for i, other := range subs {
+			if other == sub {
+				p.subs[key] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+
+		if len(p.subs[key]) == 0 {
+			delete(p.subs, key)
+		}
+	}
+}
+
+// Middleware returns the Presence as a middleware.
+func (p *Presence) Middleware(handler Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+		if !p.handle(m) {
+			handler.HandleMessage(ctx, e, m)
+		}
+	})
+}
+
+func (p *Presence) handle(m *irc.Message) bool {
+	params := m.AllParams()
+
+	var events []PresenceEvent
+
+	switch m.Command {
+	case irc.RPL_MONONLINE, irc.RPL_MONOFFLINE:
+		// <client> :target[!user@host][,target[!user@host]]*
+		if len(params) < 2 {
+			return true
+		}
+
+		for _, target := range strings.Split(params[1], ",") {
+			prefix := irc.ParsePrefix(target)
+			events = append(events, PresenceEvent{
+				Nick:   prefix.Name,
+				Online: m.Command == irc.RPL_MONONLINE,
+				Prefix: prefix,
+			})
+		}
+
+	case irc.RPL_MONLIST, irc.RPL_ENDOFMONLIST:
+		// Replies to MONITOR L, which Presence does not send.
+		return false
+
+	case irc.ERR_MONLISTFULL:
+		// <client> <limit> <targets> :Monitor list is full.
+		if len(params) < 3 {
+			return true
+		}
+
+		nicks := strings.Split(params[2], ",")
+		p.unregister(nicks...)
+
+		for _, nick := range nicks {
+			events = append(events, PresenceEvent{Nick: nick, Prefix: irc.Prefix{Name: nick}, ListFull: true})
+		}
+
+	case irc.RPL_LOGON, irc.RPL_LOGOFF, irc.RPL_NOWON, irc.RPL_NOWOFF:
+		// <client> <nick> <user> <host> <time> :<text>
+		if len(params) < 4 {
+			return true
+		}
+
+		online := m.Command == irc.RPL_LOGON || m.Command == irc.RPL_NOWON
+		event := PresenceEvent{Nick: params[1], Online: online, Prefix: irc.Prefix{Name: params[1]}}
+		if online {
+			event.Prefix.User = params[2]
+			event.Prefix.Host = params[3]
+		}
+		events = append(events, event)
+
+	case irc.RPL_WATCHOFF:
+		return true
+
+	case irc.ERR_TOOMANYWATCH:
+		// <client> <nick> :Maximum size for WATCH-list is <limit> entries
+		if len(params) < 3 {
+			return true
+		}
+
+		p.unregister(params[1])
+		events = append(events, PresenceEvent{Nick: params[1], Prefix: irc.Prefix{Name: params[1]}, ListFull: true})
+
+	case irc.RPL_ISON:
+		p.mu.Lock()
+		if len(p.ison) == 0 {
+			p.mu.Unlock()
+			return false
+		}
+
+		asked := p.ison[0]
+		p.ison = p.ison[1:]
+
+		online := make(map[string]string)
+		if len(params) > 1 {
+			for _, nick := range strings.Fields(params[1]) {
+				online[p.fold(nick)] = nick
+			}
+		}
+
+		for _, nick := range asked {
+			if actual, ok := online[p.fold(nick)]; ok {
+				events = append(events, PresenceEvent{Nick: actual, Online: true, Prefix: irc.Prefix{Name: actual}})
+			} else {
+				events = append(events, PresenceEvent{Nick: nick, Prefix: irc.Prefix{Name: nick}})
+			}
+		}
+		p.mu.Unlock()
+
+	default:
+		return false
+	}
+
+	p.dispatch(events)
+	return true
+}
+
+// unregister marks nicks which the server refused to watch as unregistered,
+// so that they are polled instead.
+func (p *Presence) unregister(nicks ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, nick := range nicks {
+		delete(p.registered, p.fold(nick))
+	}
+}
+
+// dispatch reports the events which are changes for watched nicks.
+func (p *Presence) dispatch(events []PresenceEvent) {
+	type delivery struct {
+		event PresenceEvent
+		subs  []*presenceSub
+	}
+
+	var deliveries []delivery
+
+	p.mu.Lock()
+	for _, event := range events {
+		key := p.fold(event.Nick)
+		if _, ok := p.nicks[key]; !ok {
+			continue
+		}
+
+		if !event.ListFull {
+			if online, known := p.online[key]; known && online == event.Online {
+				continue
+			}
+			p.online[key] = event.Online
+		}
+
+		deliveries = append(deliveries, delivery{event, p.subs[key]})
+	}
+	p.mu.Unlock()
+
+	for _, d := range deliveries {
+		if p.opts.OnChange != nil {
+			p.opts.OnChange(d.event)
+		}
+		for _, sub := range d.subs {
+			sub.f(d.event)
+		}
+	}
+}
+
+// register returns the messages to register nicks with the server, up to
+// its limit. p.mu must be held.
+func (p *Presence) register(nicks []string) []*irc.Message {
+	if p.mode == presenceISON {
+		return nil
+	}
+
+	var accepted []string
+	for _, nick := range nicks {
+		if p.limit > 0 && len(p.registered) >= p.limit {
+			break
+		}
+		p.registered[p.fold(nick)] = true
+		accepted = append(accepted, nick)
+	}
+
+	if p.mode == presenceMonitor {
+		return batchNicks("MONITOR", "+", ",", accepted)
+	}
+	return batchNicks("WATCH", "+", " ", accepted)
+}
+
+// poll polls the nicks which are not registered with ISON until stop is
+// closed.
+func (p *Presence) poll(stop chan struct{}) {
+	ticker := time.NewTicker(p.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		p.mu.Lock()
+
+		var nicks []string
+		for _, key := range p.order {
+			if !p.registered[key] {
+				nicks = append(nicks, p.nicks[key])
+			}
+		}
+
+		// Replies still outstanding from the last poll are forgotten, so
+		// that a server which never replies cannot grow the queue.
+		msgs := batchNicks("ISON", "", " ", nicks)
+		p.ison = make([][]string, 0, len(msgs))
+		for _, m := range msgs {
+			p.ison = append(p.ison, m.Params)
+		}
+		e := p.e
+
+		p.mu.Unlock()
+
+		// A failure to send is a failure of the connection, after which
+		// Start is called again.
+		send(e, msgs) //nolint:errcheck
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Presence) fold(nick string) string {
+	return p.is.CaseMapping().Fold(nick)
+}
+
+// batchNicks builds commands for a list of nicks, each within
+// maxPresenceLineLen. If sep is ",", the nicks are joined into a single
+// parameter after the sign; otherwise, each nick is a parameter, prefixed
+// with the sign.
+func batchNicks(command, sign, sep string, nicks []string) []*irc.Message {
+	var msgs []*irc.Message
+	var cur []string
+	length := 0
+
+	flush := func() {
+		if len(cur) == 0 {
+			return
+		}
+
+		m := &irc.Message{Command: command}
+		if sep == "," {
+			m.Params = []string{sign, strings.Join(cur, ",")}
+		} else {
+			for _, nick := range cur {
+				m.Params = append(m.Params, sign+nick)
+			}
+		}
+
+		msgs = append(msgs, m)
+		cur = nil
+		length = 0
+	}
+
+	for _, nick := range nicks {
+		if length+len(nick)+1 > maxPresenceLineLen {
+			flush()
+		}
+		cur = append(cur, nick)
+		length += len(nick) + 1
+	}
+	flush()
+
+	return msgs
+}
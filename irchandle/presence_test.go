@@ -0,0 +1,125 @@
+package irchandle
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+type syncEncoder struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *syncEncoder) Encode(m *irc.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, m.String())
+	return nil
+}
+
+func (s *syncEncoder) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.sent...)
+}
+
+func TestPresence(t *testing.T) {
+	var events []PresenceEvent
+	p := NewPresence(PresenceOptions{
+		PollInterval: time.Hour,
+		OnChange:     func(e PresenceEvent) { events = append(events, e) },
+	})
+	defer p.Close()
+
+	var bobEvents int
+	cancel := p.Subscribe("BOB", func(PresenceEvent) { bobEvents++ })
+
+	assert.NoError(t, p.Add("alice", "bob", "carol"))
+
+	is := &irc.ISupport{}
+	is.Update(&irc.Message{Command: irc.RPL_ISUPPORT, Params: []string{"me", "MONITOR=2"}})
+
+	e := &syncEncoder{}
+	assert.NoError(t, p.Start(e, is))
+
+	// The initial ISON poll happens in the background.
+	assert.Eventually(t, func() bool { return len(e.messages()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"MONITOR + alice,bob", "ISON carol"}, e.messages())
+
+	h := p.Middleware(HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+		t.Errorf("unexpected message %v", m)
+	}))
+
+	feed(t, h,
+		":irc.example.org 730 me :Alice!u@h,bob!u@h",
+		":irc.example.org 303 me :carol",
+		":irc.example.org 730 me :alice!u@h",
+		":irc.example.org 731 me :alice",
+		":irc.example.org 734 me 2 bob :Monitor list is full.",
+	)
+
+	assert.Equal(t, []PresenceEvent{
+		{Nick: "Alice", Online: true, Prefix: irc.Prefix{Name: "Alice", User: "u", Host: "h"}},
+		{Nick: "bob", Online: true, Prefix: irc.Prefix{Name: "bob", User: "u", Host: "h"}},
+		{Nick: "carol", Online: true, Prefix: irc.Prefix{Name: "carol"}},
+		{Nick: "alice", Prefix: irc.Prefix{Name: "alice"}},
+		{Nick: "bob", Prefix: irc.Prefix{Name: "bob"}, ListFull: true},
+	}, events)
+
+	on, known := p.Online("Carol")
+	assert.True(t, known)
+	assert.True(t, on)
+
+	// The server refused bob, so the last known state stands until bob is
+	// polled.
+	on, _ = p.Online("bob")
+	assert.True(t, on)
+
+	assert.Equal(t, 2, bobEvents)
+	cancel()
+
+	// Only alice is still registered with the server.
+	assert.NoError(t, p.Remove("alice", "bob", "carol"))
+	assert.Equal(t, "MONITOR - alice", e.messages()[2])
+
+	_, known = p.Online("alice")
+	assert.False(t, known)
+}
+
+func TestPresenceISON(t *testing.T) {
+	p := NewPresence(PresenceOptions{PollInterval: time.Millisecond})
+	defer p.Close()
+
+	assert.NoError(t, p.Add("alice"))
+
+	// Without ISUPPORT tokens, nicks are polled.
+	e := &syncEncoder{}
+	assert.NoError(t, p.Start(e, nil))
+
+	assert.Eventually(t, func() bool { return len(e.messages()) >= 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, "ISON alice", e.messages()[0])
+
+	// The server never replied, but only the last poll is outstanding.
+	p.mu.Lock()
+	assert.Len(t, p.ison, 1)
+	p.mu.Unlock()
+}
+
+func TestBatchNicks(t *testing.T) {
+	var nicks []string
+	for i := 0; i < 100; i++ {
+		nicks = append(nicks, "nickname")
+	}
+
+	msgs := batchNicks("WATCH", "+", " ", nicks)
+	assert.Len(t, msgs, 3)
+	assert.Equal(t, "+nickname", msgs[0].Params[0])
+	assert.Len(t, msgs[0].Params, 44)
+}
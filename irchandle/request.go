@@ -0,0 +1,78 @@
+package irchandle
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/jakebailey/irc"
+)
+
+// request sends m through e, then passes each message to f until f returns
+// done, or the context ends. Once request returns, f is not called again,
+// so the caller may read any state f has built.
+//
+// A FAIL standard reply to m's command ends the request, and is returned as
+// an *irc.StandardReply.
+func request(ctx context.Context, e irc.Encoder, w *Waiter, m *irc.Message, f ListenFunc) error {
+	return requestAll(ctx, e, w, []*irc.Message{m}, f)
+}
+
+// requestAll is request for several messages with the same command, which
+// are sent in order.
+func requestAll(ctx context.Context, e irc.Encoder, w *Waiter, msgs []*irc.Message, f ListenFunc) error {
+	var mu sync.Mutex
+	finished := false
+	var failure error
+	done := make(chan struct{})
+
+	command := msgs[0].Command
+
+	cancel := w.Listen(func(m *irc.Message) (bool, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if finished {
+			return false, true
+		}
+
+		if r, ok := irc.ParseStandardReply(m); ok && r.Fail() && strings.EqualFold(r.Command, command) {
+			failure = r
+			finished = true
+			close(done)
+			return true, true
+		}
+
+		consume, isDone := f(m)
+		if isDone {
+			finished = true
+			close(done)
+		}
+
+		return consume, isDone
+	})
+	defer cancel()
+
+	for _, m := range msgs {
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-done:
+		return failure
+	case <-ctx.Done():
+		mu.Lock()
+		defer mu.Unlock()
+
+		// The request may have finished, perhaps with a failure, at the
+		// same time.
+		if finished {
+			return failure
+		}
+		finished = true
+
+		return ctx.Err()
+	}
+}
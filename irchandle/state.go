@@ -0,0 +1,19 @@
+package irchandle
+
+import (
+	"context"
+
+	"github.com/jakebailey/irc"
+)
+
+// State returns a middleware which passes messages to s, so that it tracks
+// channels and users. All messages are passed on to the handler, after s
+// has been updated.
+func State(s *irc.State) func(Handler) Handler {
+	return func(handler Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+			s.Handle(m)
+			handler.HandleMessage(ctx, e, m)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package irchandle
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jakebailey/irc"
+)
+
+// ListenFunc is called by a Waiter for each message. If consume is true,
+// the message is not passed on to the handler, or to later listeners. If
+// done is true, the listener is removed.
+type ListenFunc func(m *irc.Message) (consume, done bool)
+
+// Waiter is a middleware which passes messages to temporary listeners
+// before the handler, which is used to wait for replies to commands. Its
+// zero value is ready to use; use its Middleware method in a Chain.
+//
+// Helpers which wait for a sequence of replies (such as a WHO reply ending
+// in RPL_ENDOFWHO) depend on message order, so the Client must be run with
+// Sync when they are used.
+type Waiter struct {
+	mu        sync.Mutex
+	listeners []*listener
+}
+
+type listener struct {
+	f       ListenFunc
+	removed bool
+}
+
+// Middleware returns the Waiter as a middleware.
+func (w *Waiter) Middleware(handler Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, e irc.Encoder, m *irc.Message) {
+		if !w.dispatch(m) {
+			handler.HandleMessage(ctx, e, m)
+		}
+	})
+}
+
+// Listen adds a listener, which is called for each message until it
+// returns done or the returned function is called. Listeners are called in
+// the order they were added, synchronously with the handler chain, so must
+// not block, other than deliberately to apply backpressure (as List does).
+func (w *Waiter) Listen(f ListenFunc) (cancel func()) {
+	l := &listener{f: f}
+
+	w.mu.Lock()
+	w.listeners = append(w.listeners, l)
+	w.mu.Unlock()
+
+	return func() { w.remove(l) }
+}
+
+func (w *Waiter) remove(l *listener) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	l.removed = true
+
+	for i, other := range w.listeners {
+		if other == l {
+			w.listeners = append(w.listeners[:i:i], w.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatch passes m to the listeners, returning true if it was consumed.
+func (w *Waiter) dispatch(m *irc.Message) bool {
+	w.mu.Lock()
+	listeners := w.listeners
+	w.mu.Unlock()
+
+	for _, l := range listeners {
+		w.mu.Lock()
+		removed := l.removed
+		w.mu.Unlock()
+
+		if removed {
+			continue
+		}
+
+		consume, done := l.f(m)
+		if done {
+			w.remove(l)
+		}
+		if consume {
+			return true
+		}
+	}
+
+	return false
+}
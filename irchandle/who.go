@@ -0,0 +1,230 @@
+package irchandle
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jakebailey/irc"
+)
+
+// DefaultWhoxFields are the WHOX fields requested by Who when
+// WhoOptions.Fields is empty.
+const DefaultWhoxFields = "cuihsnfdar"
+
+// whoxFields lists every WHOX field, in the order servers send them.
+const whoxFields = "tcuihsnfdlaor"
+
+var whoxToken uint32
+
+// WhoReply is a reply to WHO, from RPL_WHOREPLY or RPL_WHOSPCRPL (WHOX).
+// Fields which were not requested are empty.
+type WhoReply struct {
+	// Channel is a channel the user is in, or "*".
+	Channel string
+
+	Nick   string
+	User   string
+	Host   string
+	Server string
+
+	// IP is the user's IP address, which only WHOX provides.
+	IP string
+
+	// Flags are the flags sent by the server: "H" (here) or "G" (gone),
+	// followed by "*" for IRC operators and the user's channel prefixes.
+	Flags string
+
+	// Away is true if the user is away.
+	Away bool
+
+	// Operator is true if the user is an IRC operator.
+	Operator bool
+
+	Hopcount int
+
+	// Account is the user's account, which only WHOX provides.
+	Account string
+
+	RealName string
+}
+
+// WhoOptions configures Who.
+type WhoOptions struct {
+	// ISupport is the server's ISUPPORT tokens. If it includes WHOX, WHOX is
+	// used, which provides accounts.
+	ISupport *irc.ISupport
+
+	// Fields are the WHOX fields to request, such as "na" for nicks and
+	// accounts. The query type token is always added. If empty,
+	// DefaultWhoxFields is used.
+	Fields string
+}
+
+// Who sends WHO for mask, then collects the replies until RPL_ENDOFWHO. The
+// replies are not passed on to the handler. Without WHOX, which tags
+// replies with a token, only replies which match mask are collected. The
+// Waiter must be in the handler chain, with the Client run with Sync.
+func Who(ctx context.Context, e irc.Encoder, w *Waiter, mask string, opts WhoOptions) ([]*WhoReply, error) {
+	is := opts.ISupport
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+
+	whox := is.Has("WHOX")
+
+	m := &irc.Message{Command: "WHO", Params: []string{mask}}
+
+	var fields, token string
+	if whox {
+		fields = opts.Fields
+		if fields == "" {
+			fields = DefaultWhoxFields
+		}
+
+		// Servers send the fields in a fixed order, so sort them into it.
+		var sorted []byte
+		for i := 1; i < len(whoxFields); i++ {
+			if strings.IndexByte(fields, whoxFields[i]) != -1 {
+				sorted = append(sorted, whoxFields[i])
+			}
+		}
+
+		fields = string(sorted)
+		token = strconv.Itoa(int(atomic.AddUint32(&whoxToken, 1) % 1000))
+		m.Params = append(m.Params, "%t"+fields+","+token)
+	}
+
+	var replies []*WhoReply
+
+	err := request(ctx, e, w, m, func(m *irc.Message) (bool, bool) {
+		params := m.AllParams()
+
+		switch m.Command {
+		case irc.RPL_WHOREPLY:
+			if whox {
+				return false, false
+			}
+
+			// <client> <channel> <user> <host> <server> <nick> <flags> :<hopcount> <realname>
+			if len(params) < 8 {
+				return true, false
+			}
+
+			r := &WhoReply{
+				Channel: params[1],
+				User:    params[2],
+				Host:    params[3],
+				Server:  params[4],
+				Nick:    params[5],
+				Flags:   params[6],
+			}
+
+			hops, realname := params[7], ""
+			if i := strings.IndexByte(hops, ' '); i != -1 {
+				hops, realname = hops[:i], hops[i+1:]
+			}
+			r.Hopcount, _ = strconv.Atoi(hops)
+			r.RealName = realname
+
+			// Without a token, the reply can only be told apart from those
+			// to other requests by matching it against the mask.
+			if !whoMatches(is, mask, r) {
+				return false, false
+			}
+
+			replies = append(replies, r.withFlags())
+			return true, false
+
+		case irc.RPL_WHOSPCRPL:
+			// <client> <token> <fields>...
+			if !whox || len(params) < 2 || params[1] != token {
+				return false, false
+			}
+
+			replies = append(replies, parseWhox(fields, params[2:]).withFlags())
+			return true, false
+
+		case irc.RPL_ENDOFWHO:
+			if len(params) < 2 || !is.CaseMapping().Equal(params[1], mask) {
+				return false, false
+			}
+			return true, true
+		}
+
+		return false, false
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return replies, nil
+}
+
+// whoMatches reports whether r could be a reply to WHO mask: if mask is a
+// channel, r must be for that channel; otherwise, the mask must match the
+// user's nick, hostmask, or one of the other fields servers match against.
+func whoMatches(is *irc.ISupport, mask string, r *WhoReply) bool {
+	cm := is.CaseMapping()
+
+	if is.IsChannel(mask) {
+		return cm.Equal(r.Channel, mask)
+	}
+
+	// "0" is an old way of asking for every visible user.
+	if mask == "0" {
+		return true
+	}
+
+	mask = cm.Fold(mask)
+	for _, s := range []string{r.Nick, r.Nick + "!" + r.User + "@" + r.Host, r.User, r.Host, r.Server, r.RealName} {
+		if matchMask(mask, cm.Fold(s)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseWhox(fields string, values []string) *WhoReply {
+	r := &WhoReply{}
+
+	for i := 0; i < len(fields) && i < len(values); i++ {
+		v := values[i]
+
+		switch fields[i] {
+		case 'c':
+			r.Channel = v
+		case 'u':
+			r.User = v
+		case 'i':
+			r.IP = v
+		case 'h':
+			r.Host = v
+		case 's':
+			r.Server = v
+		case 'n':
+			r.Nick = v
+		case 'f':
+			r.Flags = v
+		case 'd':
+			r.Hopcount, _ = strconv.Atoi(v)
+		case 'a':
+			if v != "0" {
+				r.Account = v
+			}
+		case 'r':
+			r.RealName = v
+		}
+	}
+
+	return r
+}
+
+func (r *WhoReply) withFlags() *WhoReply {
+	r.Away = strings.HasPrefix(r.Flags, "G")
+	r.Operator = strings.IndexByte(r.Flags, '*') != -1
+	return r
+}
@@ -0,0 +1,154 @@
+package irchandle
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+// chanEncoder sends encoded messages to a channel, so that tests can reply
+// once a request has been sent.
+type chanEncoder chan *irc.Message
+
+func (c chanEncoder) Encode(m *irc.Message) error {
+	c <- m
+	return nil
+}
+
+// serve runs call in the background and, once it has sent a message, feeds
+// the lines returned by reply through the Waiter, returning call's result.
+func serve(t *testing.T, w *Waiter, call func(e irc.Encoder) error, reply func(sent *irc.Message) []string) error {
+	e := make(chanEncoder, 1)
+	errc := make(chan error, 1)
+
+	go func() { errc <- call(e) }()
+
+	h := w.Middleware(HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) {}))
+	feed(t, h, reply(<-e)...)
+
+	return <-errc
+}
+
+func TestWho(t *testing.T) {
+	w := &Waiter{}
+
+	var replies []*WhoReply
+	err := serve(t, w, func(e irc.Encoder) (err error) {
+		replies, err = Who(context.Background(), e, w, "#chan", WhoOptions{})
+		return err
+	}, func(sent *irc.Message) []string {
+		assert.Equal(t, []string{"#chan"}, sent.Params)
+		return []string{
+			":irc.example.org 352 me #other ~c c.org irc.example.org carol H :0 Carol",
+			":irc.example.org 352 me #chan ~a a.org irc.example.org alice H*@ :0 Alice A",
+			":irc.example.org 352 me #CHAN ~b b.org irc.example.org bob G :2 Bob",
+			":irc.example.org 315 me #CHAN :End of /WHO list.",
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []*WhoReply{
+		{Channel: "#chan", Nick: "alice", User: "~a", Host: "a.org", Server: "irc.example.org", Flags: "H*@", Operator: true, RealName: "Alice A"},
+		{Channel: "#CHAN", Nick: "bob", User: "~b", Host: "b.org", Server: "irc.example.org", Flags: "G", Away: true, Hopcount: 2, RealName: "Bob"},
+	}, replies)
+}
+
+func TestWhoMask(t *testing.T) {
+	w := &Waiter{}
+
+	var replies []*WhoReply
+	err := serve(t, w, func(e irc.Encoder) (err error) {
+		replies, err = Who(context.Background(), e, w, "*.b.org", WhoOptions{})
+		return err
+	}, func(sent *irc.Message) []string {
+		return []string{
+			":irc.example.org 352 me #chan ~a a.org irc.example.org alice H :0 Alice",
+			":irc.example.org 352 me * ~b host.B.org irc.example.org bob H :0 Bob",
+			":irc.example.org 315 me *.b.org :End of /WHO list.",
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, replies, 1)
+	assert.Equal(t, "bob", replies[0].Nick)
+}
+
+func TestWhox(t *testing.T) {
+	w := &Waiter{}
+
+	is := &irc.ISupport{}
+	is.Update(&irc.Message{Command: irc.RPL_ISUPPORT, Params: []string{"me", "WHOX"}})
+
+	var replies []*WhoReply
+	err := serve(t, w, func(e irc.Encoder) (err error) {
+		replies, err = Who(context.Background(), e, w, "#chan", WhoOptions{ISupport: is, Fields: "an"})
+		return err
+	}, func(sent *irc.Message) []string {
+		token := sent.Params[1][len("%tna,"):]
+		assert.Equal(t, "%tna,"+token, sent.Params[1])
+		return []string{
+			":irc.example.org 354 me 999 other 0",
+			":irc.example.org 354 me " + token + " alice alice_acct",
+			":irc.example.org 354 me " + token + " bob 0",
+			":irc.example.org 315 me #chan :End of /WHO list.",
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []*WhoReply{
+		{Nick: "alice", Account: "alice_acct"},
+		{Nick: "bob"},
+	}, replies)
+}
+
+func TestWhoFail(t *testing.T) {
+	w := &Waiter{}
+
+	err := serve(t, w, func(e irc.Encoder) error {
+		_, err := Who(context.Background(), e, w, "#chan", WhoOptions{})
+		return err
+	}, func(sent *irc.Message) []string {
+		return []string{
+			":irc.example.org FAIL PRIVMSG INVALID_TARGET #other :Unrelated",
+			":irc.example.org FAIL WHO TOO_MANY_REQUESTS :Slow down",
+		}
+	})
+
+	reply, ok := err.(*irc.StandardReply)
+	assert.True(t, ok)
+	assert.Equal(t, "TOO_MANY_REQUESTS", reply.Code)
+}
+
+// replyEncoder feeds the reply lines through h as soon as anything is sent.
+type replyEncoder struct {
+	t     *testing.T
+	h     Handler
+	lines []string
+}
+
+func (r *replyEncoder) Encode(*irc.Message) error {
+	feed(r.t, r.h, r.lines...)
+	return nil
+}
+
+func TestWhoFailCanceled(t *testing.T) {
+	w := &Waiter{}
+	e := &replyEncoder{
+		t:     t,
+		h:     w.Middleware(HandlerFunc(func(context.Context, irc.Encoder, *irc.Message) {})),
+		lines: []string{":irc.example.org FAIL WHO TOO_MANY_REQUESTS :Slow down"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The request has failed as well as been canceled, so whichever is
+	// noticed first, the failure is not lost.
+	for i := 0; i < 20; i++ {
+		_, err := Who(ctx, e, w, "#chan", WhoOptions{})
+		_, ok := err.(*irc.StandardReply)
+		assert.True(t, ok, "got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package irchandle
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jakebailey/irc"
+)
+
+// WhoisReply is the reply to WHOIS. Fields which the server did not send
+// are empty.
+type WhoisReply struct {
+	Nick     string
+	User     string
+	Host     string
+	RealName string
+
+	// Server is the server the user is connected to, and ServerInfo its
+	// description.
+	Server     string
+	ServerInfo string
+
+	// Operator is true if the user is an IRC operator.
+	Operator bool
+
+	Idle   time.Duration
+	SignOn time.Time
+
+	// Channels are the channels the user is in, with the user's prefixes,
+	// for example "@#channel".
+	Channels []string
+
+	Account string
+
+	// Secure is true if the user is connected using TLS.
+	Secure bool
+
+	// CertFP is the fingerprint of the user's TLS client certificate.
+	CertFP string
+
+	// ConnectingFrom is the user's real host and IP address, as described
+	// by RPL_WHOISHOST, which servers only send to operators.
+	ConnectingFrom string
+
+	Away        bool
+	AwayMessage string
+}
+
+// WhoisOptions configures Whois.
+type WhoisOptions struct {
+	// ISupport provides the server's casemapping. If nil, the default is
+	// used.
+	ISupport *irc.ISupport
+}
+
+// Whois sends WHOIS for nick, then collects the replies until
+// RPL_ENDOFWHOIS. If the nick does not exist, an *irc.NumericError is
+// returned, which wraps irc.ErrNoSuchNick or irc.ErrNoSuchServer. The
+// replies are not passed on to the handler. The Waiter must be in the
+// handler chain, with the Client run with Sync.
+func Whois(ctx context.Context, e irc.Encoder, w *Waiter, nick string, opts WhoisOptions) (*WhoisReply, error) {
+	is := opts.ISupport
+	if is == nil {
+		is = &irc.ISupport{}
+	}
+	cm := is.CaseMapping()
+
+	r := &WhoisReply{Nick: nick}
+	var whoisErr *irc.NumericError
+
+	m := &irc.Message{Command: "WHOIS", Params: []string{nick}}
+
+	err := request(ctx, e, w, m, func(m *irc.Message) (bool, bool) {
+		params := m.AllParams()
+
+		// Every reply is of the form <client> <nick> ...
+		if len(params) < 2 || !cm.Equal(params[1], nick) {
+			return false, false
+		}
+
+		last := params[len(params)-1]
+
+		switch m.Command {
+		case irc.RPL_WHOISUSER:
+			// <client> <nick> <user> <host> * :<realname>
+			if len(params) > 5 {
+				r.Nick = params[1]
+				r.User = params[2]
+				r.Host = params[3]
+				r.RealName = params[5]
+			}
+
+		case irc.RPL_WHOISSERVER:
+			// <client> <nick> <server> :<server info>
+			if len(params) > 3 {
+				r.Server = params[2]
+				r.ServerInfo = params[3]
+			}
+
+		case irc.RPL_WHOISOPERATOR:
+			r.Operator = true
+
+		case irc.RPL_WHOISIDLE:
+			// <client> <nick> <secs> [<signon>] :seconds idle, signon time
+			if len(params) > 2 {
+				secs, _ := strconv.Atoi(params[2])
+				r.Idle = time.Duration(secs) * time.Second
+			}
+			if len(params) > 4 {
+				if signon, err := strconv.ParseInt(params[3], 10, 64); err == nil {
+					r.SignOn = time.Unix(signon, 0)
+				}
+			}
+
+		case irc.RPL_WHOISCHANNELS:
+			r.Channels = append(r.Channels, strings.Fields(last)...)
+
+		case irc.RPL_WHOISACCOUNT:
+			// <client> <nick> <account> :is logged in as
+			if len(params) > 3 {
+				r.Account = params[2]
+			}
+
+		case irc.RPL_WHOISSECURE:
+			r.Secure = true
+
+		case irc.RPL_WHOISCERTFP:
+			// <client> <nick> :has client certificate fingerprint <fingerprint>
+			if fields := strings.Fields(last); len(fields) > 0 {
+				r.CertFP = fields[len(fields)-1]
+			}
+
+		case irc.RPL_WHOISHOST:
+			// <client> <nick> :is connecting from <user>@<host> <ip>
+			if i := strings.Index(last, "from "); i != -1 {
+				r.ConnectingFrom = last[i+len("from "):]
+			} else {
+				r.ConnectingFrom = last
+			}
+
+		case irc.RPL_AWAY:
+			r.Away = true
+			r.AwayMessage = last
+
+		case irc.ERR_NOSUCHNICK, irc.ERR_NOSUCHSERVER:
+			// RPL_ENDOFWHOIS still follows, so keep consuming until then.
+			whoisErr, _ = irc.ParseNumericError(m)
+
+		case irc.RPL_ENDOFWHOIS:
+			return true, true
+
+		default:
+			return false, false
+		}
+
+		return true, false
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if whoisErr != nil {
+		return nil, whoisErr
+	}
+
+	return r, nil
+}
@@ -0,0 +1,87 @@
+package irchandle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhois(t *testing.T) {
+	w := &Waiter{}
+
+	var r *WhoisReply
+	err := serve(t, w, func(e irc.Encoder) (err error) {
+		r, err = Whois(context.Background(), e, w, "alice[m]", WhoisOptions{})
+		return err
+	}, func(sent *irc.Message) []string {
+		assert.Equal(t, "WHOIS", sent.Command)
+		return []string{
+			":irc.example.org 311 me Alice{m} ~a a.org * :Alice A",
+			":irc.example.org 319 me Alice{m} :@#chan +#other",
+			":irc.example.org 319 me Alice{m} :#third",
+			":irc.example.org 312 me Alice{m} irc.example.org :Example server",
+			":irc.example.org 301 me Alice{m} :out to lunch",
+			":irc.example.org 313 me Alice{m} :is an IRC operator",
+			":irc.example.org 671 me Alice{m} :is using a secure connection",
+			":irc.example.org 276 me Alice{m} :has client certificate fingerprint abcdef",
+			":irc.example.org 378 me Alice{m} :is connecting from ~a@203.0.113.9 203.0.113.9",
+			":irc.example.org 330 me Alice{m} alice_acct :is logged in as",
+			":irc.example.org 317 me Alice{m} 42 1500000000 :seconds idle, signon time",
+			":irc.example.org 318 me Alice{m} :End of /WHOIS list.",
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, &WhoisReply{
+		Nick:           "Alice{m}",
+		User:           "~a",
+		Host:           "a.org",
+		RealName:       "Alice A",
+		Server:         "irc.example.org",
+		ServerInfo:     "Example server",
+		Operator:       true,
+		Idle:           42 * time.Second,
+		SignOn:         time.Unix(1500000000, 0),
+		Channels:       []string{"@#chan", "+#other", "#third"},
+		Account:        "alice_acct",
+		Secure:         true,
+		CertFP:         "abcdef",
+		ConnectingFrom: "~a@203.0.113.9 203.0.113.9",
+		Away:           true,
+		AwayMessage:    "out to lunch",
+	}, r)
+}
+
+func TestWhoisNoSuchNick(t *testing.T) {
+	w := &Waiter{}
+	e := make(chanEncoder, 1)
+	errc := make(chan error, 1)
+
+	go func() {
+		_, err := Whois(context.Background(), e, w, "nobody", WhoisOptions{})
+		errc <- err
+	}()
+	<-e
+
+	var passed []string
+	h := w.Middleware(HandlerFunc(func(_ context.Context, _ irc.Encoder, m *irc.Message) {
+		passed = append(passed, m.Command)
+	}))
+
+	// RPL_ENDOFWHOIS follows the error, and is consumed too.
+	feed(t, h,
+		":irc.example.org 401 me nobody :No such nick/channel",
+		":irc.example.org 318 me nobody :End of /WHOIS list.",
+	)
+	err := <-errc
+
+	var numErr *irc.NumericError
+	assert.True(t, errors.As(err, &numErr))
+	assert.Equal(t, "nobody", numErr.Target)
+	assert.True(t, errors.Is(err, irc.ErrNoSuchNick))
+	assert.Empty(t, passed)
+}
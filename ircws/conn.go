@@ -0,0 +1,112 @@
+package ircws
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/jakebailey/irc"
+)
+
+var (
+	// ErrInvalidUTF8 is returned by Conn.Encode and Conn.Decode when a
+	// message is not valid UTF-8 and the connection uses the text
+	// subprotocol.
+	ErrInvalidUTF8 = errors.New("ircws: message is not valid utf-8")
+
+	// ErrMultipleMessages is returned by Conn.Decode when a frame holds more
+	// than one line, as each frame must hold exactly one message.
+	ErrMultipleMessages = errors.New("ircws: frame contains more than one message")
+)
+
+// Conn is an IRC connection over a WebSocket.
+type Conn struct {
+	ws       *websocket.Conn
+	msgType  int
+	decodeMu sync.Mutex
+	encodeMu sync.Mutex
+}
+
+var _ irc.Conn = (*Conn)(nil)
+
+// NewConn creates a new Conn from an established WebSocket connection,
+// using the negotiated subprotocol to choose between text and binary
+// frames. If no subprotocol was negotiated, text is used.
+func NewConn(ws *websocket.Conn) *Conn {
+	msgType := websocket.TextMessage
+	if ws.Subprotocol() == BinarySubprotocol {
+		msgType = websocket.BinaryMessage
+	}
+
+	return &Conn{
+		ws:      ws,
+		msgType: msgType,
+	}
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *Conn) Subprotocol() string {
+	return c.ws.Subprotocol()
+}
+
+// Close sends a close frame and closes the underlying connection.
+func (c *Conn) Close() error {
+	c.encodeMu.Lock()
+	// The peer may already be gone, in which case there is nothing to do
+	// with the error.
+	c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")) //nolint:errcheck
+	c.encodeMu.Unlock()
+
+	return c.ws.Close()
+}
+
+// Encode encodes a message as a single WebSocket message.
+func (c *Conn) Encode(m *irc.Message) error {
+	b := m.Bytes()
+
+	if c.msgType == websocket.TextMessage && !utf8.Valid(b) {
+		return ErrInvalidUTF8
+	}
+
+	c.encodeMu.Lock()
+	defer c.encodeMu.Unlock()
+
+	return c.ws.WriteMessage(c.msgType, b)
+}
+
+// Decode decodes a message into the argument, which cannot be nil. When the
+// peer closes the connection, io.EOF is returned.
+func (c *Conn) Decode(m *irc.Message) error {
+	c.decodeMu.Lock()
+	defer c.decodeMu.Unlock()
+
+	_, b, err := c.ws.ReadMessage()
+	if err != nil {
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+			return io.EOF
+		}
+		return err
+	}
+
+	if c.msgType == websocket.TextMessage && !utf8.Valid(b) {
+		return ErrInvalidUTF8
+	}
+
+	// Line endings are not sent over WebSockets, but be lenient with a
+	// trailing one.
+	line := strings.TrimRight(string(b), "\r\n")
+	if strings.ContainsAny(line, "\r\n") {
+		return ErrMultipleMessages
+	}
+
+	if err := m.Parse(line); err != nil {
+		return err
+	}
+
+	m.Received = time.Now()
+	return nil
+}
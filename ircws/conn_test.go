@@ -0,0 +1,177 @@
+package ircws
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jakebailey/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+const rawTwitch = `@badges=;color=;display-name=ZZZi;emotes=;id=d8f0ab3c-a2d2-4d3b-8fdb-f3dc4c1e1bd1;mod=0;room-id=29829912;sent-ts=1504316564218;subscriber=0;tmi-sent-ts=1504316564327;turbo=0;user-id=157761223;user-type= :zzzi!zzzi@zzzi.tmi.twitch.tv PRIVMSG #channel :this is a test message`
+
+func echoServer(t *testing.T) *httptest.Server {
+	h := &Handler{
+		Serve: func(c *Conn, r *http.Request) {
+			for {
+				var m irc.Message
+				if err := c.Decode(&m); err != nil {
+					return
+				}
+				if m.Command == "QUIT" {
+					return
+				}
+				if err := c.Encode(&m); err != nil {
+					return
+				}
+			}
+		},
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	return httptest.NewServer(h)
+}
+
+func wsURL(s *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func testRoundTrip(t *testing.T, c *Conn) {
+	m, err := irc.ParseMessage(rawTwitch)
+	assert.NoError(t, err)
+	m.Raw = "" // Don't check raw.
+
+	assert.NoError(t, c.Encode(m))
+
+	var got irc.Message
+	assert.NoError(t, c.Decode(&got))
+
+	assert.False(t, got.Received.IsZero())
+
+	got.Raw = ""
+	got.Received = time.Time{}
+	assert.Equal(t, m, &got)
+
+	assert.NoError(t, c.Encode(&irc.Message{Command: "QUIT"}))
+	assert.Equal(t, io.EOF, c.Decode(&got))
+	assert.NoError(t, c.Close())
+}
+
+func TestDialBinary(t *testing.T) {
+	s := echoServer(t)
+	defer s.Close()
+
+	c, err := Dial(wsURL(s))
+	assert.NoError(t, err)
+	assert.Equal(t, BinarySubprotocol, c.Subprotocol())
+
+	testRoundTrip(t, c)
+}
+
+func TestDialText(t *testing.T) {
+	s := echoServer(t)
+	defer s.Close()
+
+	d := websocket.Dialer{Subprotocols: []string{TextSubprotocol}}
+	ws, _, err := d.Dial(wsURL(s), nil)
+	assert.NoError(t, err)
+
+	c := NewConn(ws)
+	assert.Equal(t, TextSubprotocol, c.Subprotocol())
+
+	assert.Equal(t, ErrInvalidUTF8, c.Encode(&irc.Message{Command: "PRIVMSG", Params: []string{"#a"}, Trailing: "\xff"}))
+
+	testRoundTrip(t, c)
+}
+
+func TestDialNoSubprotocol(t *testing.T) {
+	s := echoServer(t)
+	defer s.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL(s), nil)
+	assert.NoError(t, err)
+
+	c := NewConn(ws)
+	assert.Equal(t, "", c.Subprotocol())
+
+	testRoundTrip(t, c)
+}
+
+func TestDecodeLineEnding(t *testing.T) {
+	s := httptest.NewServer(&Handler{
+		Serve: func(c *Conn, r *http.Request) {
+			// Write directly, to send a frame with a line ending.
+			c.ws.WriteMessage(websocket.BinaryMessage, []byte("PING :x\r\n")) //nolint:errcheck
+		},
+	})
+	defer s.Close()
+
+	var d Dialer
+	c, err := d.DialContext(context.Background(), wsURL(s))
+	assert.NoError(t, err)
+	defer c.Close()
+
+	var m irc.Message
+	assert.NoError(t, c.Decode(&m))
+	assert.Equal(t, "PING", m.Command)
+	assert.Equal(t, "x", m.Trailing)
+
+	assert.Equal(t, io.EOF, c.Decode(&m))
+}
+
+func TestDecodeMultipleMessages(t *testing.T) {
+	s := httptest.NewServer(&Handler{
+		Serve: func(c *Conn, r *http.Request) {
+			// Write directly, to send two lines in one frame.
+			c.ws.WriteMessage(websocket.BinaryMessage, []byte("PING :x\r\nPING :y")) //nolint:errcheck
+		},
+	})
+	defer s.Close()
+
+	var d Dialer
+	c, err := d.DialContext(context.Background(), wsURL(s))
+	assert.NoError(t, err)
+	defer c.Close()
+
+	var m irc.Message
+	assert.Equal(t, ErrMultipleMessages, c.Decode(&m))
+}
+
+func TestDecodeInvalidUTF8(t *testing.T) {
+	s := httptest.NewServer(&Handler{
+		Serve: func(c *Conn, r *http.Request) {
+			// Write directly, as Encode refuses invalid UTF-8.
+			c.ws.WriteMessage(websocket.TextMessage, []byte("PRIVMSG #a :\xff")) //nolint:errcheck
+		},
+	})
+	defer s.Close()
+
+	d := websocket.Dialer{Subprotocols: []string{TextSubprotocol}}
+	ws, _, err := d.Dial(wsURL(s), nil)
+	assert.NoError(t, err)
+
+	c := NewConn(ws)
+	defer c.Close()
+
+	var m irc.Message
+	assert.Equal(t, ErrInvalidUTF8, c.Decode(&m))
+}
+
+func TestHandlerOrigin(t *testing.T) {
+	s := httptest.NewServer(&Handler{
+		Serve: func(c *Conn, r *http.Request) {
+			t.Error("unexpected connection")
+		},
+	})
+	defer s.Close()
+
+	d := Dialer{Header: http.Header{"Origin": []string{"https://example.com"}}}
+	_, err := d.DialContext(context.Background(), wsURL(s))
+	assert.Error(t, err)
+}
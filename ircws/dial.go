@@ -0,0 +1,58 @@
+package ircws
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Dialer contains options for connecting to an IRC server over WebSockets.
+// The zero value is a usable Dialer.
+type Dialer struct {
+	// NetDialContext, if set, is used to create the underlying network
+	// connection, for example to connect through a proxy.
+	NetDialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// TLSClientConfig is used for wss:// URLs. If nil, the default
+	// configuration is used.
+	TLSClientConfig *tls.Config
+
+	// Header is sent with the opening handshake, for example to set an
+	// Origin header.
+	Header http.Header
+
+	// HandshakeTimeout is the maximum duration of the opening handshake.
+	// If zero, no timeout is used beyond the context's.
+	HandshakeTimeout time.Duration
+}
+
+// Dial is shorthand for calling DialContext with the zero Dialer and a
+// background context.
+func Dial(url string) (*Conn, error) {
+	var d Dialer
+	return d.DialContext(context.Background(), url)
+}
+
+// DialContext connects to the ws:// or wss:// URL, offering both IRCv3
+// subprotocols.
+func (d *Dialer) DialContext(ctx context.Context, url string) (*Conn, error) {
+	wd := &websocket.Dialer{
+		NetDialContext:   d.NetDialContext,
+		TLSClientConfig:  d.TLSClientConfig,
+		HandshakeTimeout: d.HandshakeTimeout,
+		Subprotocols:     subprotocols,
+		Proxy:            http.ProxyFromEnvironment,
+	}
+
+	ws, resp, err := wd.DialContext(ctx, url, d.Header)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return NewConn(ws), nil
+}
@@ -0,0 +1,43 @@
+package ircws
+
+import (
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Handler is an http.Handler which accepts IRC clients over WebSockets.
+type Handler struct {
+	// Serve is called with each accepted connection, in the goroutine
+	// which is serving the HTTP request. The connection is closed once Serve
+	// returns.
+	Serve func(c *Conn, r *http.Request)
+
+	// CheckOrigin returns true if the request's Origin header is
+	// acceptable. If nil, only requests whose Origin matches the Host are
+	// accepted, as browsers allow any page to open a WebSocket.
+	CheckOrigin func(r *http.Request) bool
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// ServeHTTP upgrades the request to a WebSocket, selecting the binary
+// subprotocol if the client offers it, then text. Clients which offer
+// neither are accepted using text frames.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u := websocket.Upgrader{
+		Subprotocols: subprotocols,
+		CheckOrigin:  h.CheckOrigin,
+	}
+
+	ws, err := u.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error.
+		return
+	}
+
+	c := NewConn(ws)
+	defer c.Close()
+
+	h.Serve(c, r)
+}
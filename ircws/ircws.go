@@ -0,0 +1,20 @@
+// Package ircws implements the IRCv3 WebSocket transport, as described at
+// https://ircv3.net/specs/extensions/websocket.
+//
+// Each IRC message is sent as a single WebSocket message, without a line
+// ending. The text.ircv3.net subprotocol sends messages as text frames, which
+// must be valid UTF-8, and the binary.ircv3.net subprotocol sends messages as
+// binary frames.
+package ircws
+
+const (
+	// TextSubprotocol is the subprotocol for UTF-8 text frames.
+	TextSubprotocol = "text.ircv3.net"
+
+	// BinarySubprotocol is the subprotocol for binary frames.
+	BinarySubprotocol = "binary.ircv3.net"
+)
+
+// subprotocols is the order in which subprotocols are offered and selected.
+// Binary is preferred, as IRC does not guarantee UTF-8.
+var subprotocols = []string{BinarySubprotocol, TextSubprotocol}
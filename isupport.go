@@ -0,0 +1,214 @@
+package irc
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ISupport holds the tokens advertised by a server in RPL_ISUPPORT (005)
+// replies. It is safe for concurrent use. The zero value is empty and
+// ready to use.
+type ISupport struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+// Update adds or removes the tokens in an RPL_ISUPPORT message. Messages
+// with other commands are ignored.
+func (is *ISupport) Update(m *Message) {
+	if m.Command != RPL_ISUPPORT || len(m.Params) < 2 {
+		return
+	}
+
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if is.tokens == nil {
+		is.tokens = make(map[string]string)
+	}
+
+	// The first param is the client's nick.
+	for _, token := range m.Params[1:] {
+		if token == "" {
+			continue
+		}
+
+		if token[0] == '-' {
+			delete(is.tokens, token[1:])
+			continue
+		}
+
+		k, v := token, ""
+		if i := strings.IndexByte(token, '='); i != -1 {
+			k, v = token[:i], isupportUnescape(token[i+1:])
+		}
+
+		is.tokens[k] = v
+	}
+}
+
+// Get returns the value of a token, and whether the token is present.
+func (is *ISupport) Get(token string) (value string, ok bool) {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+
+	value, ok = is.tokens[token]
+	return value, ok
+}
+
+// Has returns true if the token is present.
+func (is *ISupport) Has(token string) bool {
+	_, ok := is.Get(token)
+	return ok
+}
+
+// Int returns the value of a token as an integer. ok is false if the token
+// is not present or its value is not an integer.
+func (is *ISupport) Int(token string) (n int, ok bool) {
+	v, ok := is.Get(token)
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// Tokens returns a copy of all tokens.
+func (is *ISupport) Tokens() map[string]string {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+
+	tokens := make(map[string]string, len(is.tokens))
+	for k, v := range is.tokens {
+		tokens[k] = v
+	}
+	return tokens
+}
+
+// Defaults used when the server does not send the corresponding token.
+const (
+	defaultPrefix    = "(ov)@+"
+	defaultChanModes = "beI,k,l,imnpst"
+	defaultChanTypes = "#&"
+)
+
+// CaseMapping returns the server's casemapping, defaulting to
+// CaseMappingRFC1459.
+func (is *ISupport) CaseMapping() CaseMapping {
+	v, ok := is.Get("CASEMAPPING")
+	if !ok || v == "" {
+		return CaseMappingRFC1459
+	}
+	return CaseMapping(v)
+}
+
+// Prefix returns the channel membership modes and their prefixes from
+// PREFIX, in order of rank, for example "ov" and "@+".
+func (is *ISupport) Prefix() (modes, prefixes string) {
+	v, ok := is.Get("PREFIX")
+	if !ok {
+		v = defaultPrefix
+	}
+
+	if v == "" || v[0] != '(' {
+		return "", ""
+	}
+
+	i := strings.IndexByte(v, ')')
+	if i == -1 || len(v)-i-1 != i-1 {
+		return "", ""
+	}
+
+	return v[1:i], v[i+1:]
+}
+
+// ChanModes returns the four classes of channel modes from CHANMODES:
+// modes which are lists (A), modes which always take a parameter (B), modes
+// which take a parameter only when set (C), and modes which never take one
+// (D).
+func (is *ISupport) ChanModes() (a, b, c, d string) {
+	v, ok := is.Get("CHANMODES")
+	if !ok {
+		v = defaultChanModes
+	}
+
+	classes := strings.SplitN(v, ",", 4)
+	for len(classes) < 4 {
+		classes = append(classes, "")
+	}
+
+	// Any further classes are not defined, so are dropped.
+	if i := strings.IndexByte(classes[3], ','); i != -1 {
+		classes[3] = classes[3][:i]
+	}
+
+	return classes[0], classes[1], classes[2], classes[3]
+}
+
+// ChanTypes returns the channel name prefixes from CHANTYPES.
+func (is *ISupport) ChanTypes() string {
+	v, ok := is.Get("CHANTYPES")
+	if !ok {
+		return defaultChanTypes
+	}
+	return v
+}
+
+// IsChannel returns true if name is a channel name, according to
+// CHANTYPES.
+func (is *ISupport) IsChannel(name string) bool {
+	return name != "" && strings.IndexByte(is.ChanTypes(), name[0]) != -1
+}
+
+// TargMax returns the maximum number of targets the server accepts for
+// command, from TARGMAX, or 0 if there is no limit or it is not known.
+// Without TARGMAX, the older MAXTARGETS applies to PRIVMSG and NOTICE.
+func (is *ISupport) TargMax(command string) int {
+	v, ok := is.Get("TARGMAX")
+	if !ok {
+		if !strings.EqualFold(command, "PRIVMSG") && !strings.EqualFold(command, "NOTICE") {
+			return 0
+		}
+		n, _ := is.Int("MAXTARGETS")
+		return n
+	}
+
+	for _, entry := range strings.Split(v, ",") {
+		name, limit, _ := strings.Cut(entry, ":")
+		if strings.EqualFold(name, command) {
+			n, _ := strconv.Atoi(limit)
+			return n
+		}
+	}
+
+	return 0
+}
+
+// isupportUnescape replaces \xHH escapes in a token value.
+func isupportUnescape(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestISupportTargMax(t *testing.T) {
+	is := &ISupport{}
+	assert.Equal(t, 0, is.TargMax("JOIN"))
+
+	is.Update(&Message{Command: RPL_ISUPPORT, Params: []string{"me", "MAXTARGETS=3"}})
+	assert.Equal(t, 3, is.TargMax("PRIVMSG"))
+	assert.Equal(t, 3, is.TargMax("notice"))
+	assert.Equal(t, 0, is.TargMax("JOIN"))
+
+	is.Update(&Message{Command: RPL_ISUPPORT, Params: []string{"me", "TARGMAX=PRIVMSG:4,JOIN:,names:1"}})
+	assert.Equal(t, 4, is.TargMax("PRIVMSG"))
+	assert.Equal(t, 0, is.TargMax("JOIN"))
+	assert.Equal(t, 1, is.TargMax("NAMES"))
+	assert.Equal(t, 0, is.TargMax("KICK"))
+}
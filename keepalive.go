@@ -0,0 +1,219 @@
+package irc
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PingTimeoutError is returned by KeepaliveConn.Decode when the connection
+// was closed because the server did not reply to a PING in time.
+type PingTimeoutError struct {
+	// After is how long the connection waited for a reply.
+	After time.Duration
+}
+
+func (e *PingTimeoutError) Error() string {
+	return "irc: ping timeout after " + e.After.String()
+}
+
+// Timeout returns true, implementing net.Error.
+func (e *PingTimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary returns false, implementing net.Error.
+func (e *PingTimeoutError) Temporary() bool {
+	return false
+}
+
+// KeepaliveOptions configures a KeepaliveConn.
+type KeepaliveOptions struct {
+	// Interval is how long the connection may be idle before a PING is
+	// sent. If zero, one minute is used.
+	Interval time.Duration
+
+	// Timeout is how long to wait for the PONG before closing the
+	// connection. If zero, 30 seconds is used.
+	Timeout time.Duration
+}
+
+// KeepaliveConn is a Conn which detects dead connections. After a period
+// without receiving anything, it sends a PING with a unique token, and
+// closes the connection if the matching PONG does not arrive in time. The
+// round-trip time of the last PING is available from Lag.
+//
+// KeepaliveConn also replies to PINGs from the server, so handlers should
+// not. Server PINGs are still returned from Decode; PONGs in reply to the
+// KeepaliveConn's own PINGs are not.
+type KeepaliveConn struct {
+	conn Conn
+	opts KeepaliveOptions
+
+	mu       sync.Mutex
+	lastRecv time.Time
+	token    string
+	sent     time.Time
+	lag      time.Duration
+	seq      uint64
+	err      error
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ Conn = (*KeepaliveConn)(nil)
+
+// NewKeepaliveConn wraps a Conn, and starts the goroutine which sends PINGs.
+// Close must be called to stop it.
+func NewKeepaliveConn(conn Conn, opts KeepaliveOptions) *KeepaliveConn {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	k := &KeepaliveConn{
+		conn:     conn,
+		opts:     opts,
+		lastRecv: time.Now(),
+		done:     make(chan struct{}),
+	}
+
+	go k.run()
+
+	return k
+}
+
+// Lag returns the round-trip time of the most recently answered PING, or
+// zero if no PING has been answered yet.
+func (k *KeepaliveConn) Lag() time.Duration {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.lag
+}
+
+// Close stops sending PINGs and closes the underlying connection.
+func (k *KeepaliveConn) Close() error {
+	k.closeOnce.Do(func() { close(k.done) })
+	return k.conn.Close()
+}
+
+// Encode encodes a message over the underlying connection.
+func (k *KeepaliveConn) Encode(m *Message) error {
+	return k.conn.Encode(m)
+}
+
+// Decode decodes a message from the underlying connection, replying to
+// PINGs and consuming replies to the KeepaliveConn's own PINGs. If the
+// connection was closed due to a ping timeout, a *PingTimeoutError is
+// returned.
+func (k *KeepaliveConn) Decode(m *Message) error {
+	for {
+		if err := k.conn.Decode(m); err != nil {
+			k.mu.Lock()
+			defer k.mu.Unlock()
+
+			if k.err != nil {
+				return k.err
+			}
+			return err
+		}
+
+		now := time.Now()
+
+		k.mu.Lock()
+		k.lastRecv = now
+		ours := m.Command == "PONG" && k.token != "" && pongToken(m) == k.token
+		if ours {
+			k.lag = now.Sub(k.sent)
+			k.token = ""
+		}
+		k.mu.Unlock()
+
+		if ours {
+			continue
+		}
+
+		if m.Command == "PING" {
+			pong := &Message{
+				Command:        "PONG",
+				Params:         m.Params,
+				Trailing:       m.Trailing,
+				ForcedTrailing: m.ForcedTrailing,
+			}
+			if err := k.conn.Encode(pong); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func (k *KeepaliveConn) run() {
+	t := time.NewTimer(k.opts.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-k.done:
+			return
+		case <-t.C:
+		}
+
+		next, ping, timedOut := k.tick(time.Now())
+
+		if timedOut {
+			k.conn.Close()
+			return
+		}
+
+		if ping != nil {
+			// A failed write will also fail the next Decode, so the error
+			// need not be handled here.
+			k.conn.Encode(ping) //nolint:errcheck
+		}
+
+		t.Reset(next)
+	}
+}
+
+// tick checks the state of the connection at time now, returning how long
+// to wait before checking again, and a PING to send if one is due.
+func (k *KeepaliveConn) tick(now time.Time) (next time.Duration, ping *Message, timedOut bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.token != "" {
+		waited := now.Sub(k.sent)
+		if waited >= k.opts.Timeout {
+			k.err = &PingTimeoutError{After: waited}
+			return 0, nil, true
+		}
+		return k.opts.Timeout - waited, nil, false
+	}
+
+	idle := now.Sub(k.lastRecv)
+	if idle < k.opts.Interval {
+		return k.opts.Interval - idle, nil, false
+	}
+
+	k.seq++
+	k.token = "keepalive-" + strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatUint(k.seq, 36)
+	k.sent = now
+
+	return k.opts.Timeout, &Message{Command: "PING", Trailing: k.token}, false
+}
+
+// pongToken returns the token of a PONG, which servers send as the last
+// parameter.
+func pongToken(m *Message) string {
+	if m.Trailing != "" || m.ForcedTrailing || len(m.Params) == 0 {
+		return m.Trailing
+	}
+	return m.Params[len(m.Params)-1]
+}
@@ -0,0 +1,100 @@
+package irc
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepaliveLag(t *testing.T) {
+	client, server := net.Pipe()
+	k := NewKeepaliveConn(NewBaseConn(client), KeepaliveOptions{Interval: 10 * time.Millisecond})
+	defer k.Close()
+
+	s := NewBaseConn(server)
+	defer s.Close()
+
+	go func() {
+		var m Message
+		if err := s.Decode(&m); err != nil {
+			return
+		}
+
+		time.Sleep(5 * time.Millisecond)
+
+		pong := &Message{
+			Prefix:   Prefix{Name: "irc.example.org"},
+			Command:  "PONG",
+			Params:   []string{"irc.example.org"},
+			Trailing: m.Trailing,
+		}
+		s.Encode(pong)                                                                    //nolint:errcheck
+		s.Encode(&Message{Command: "PRIVMSG", Params: []string{"#a"}, Trailing: "after"}) //nolint:errcheck
+	}()
+
+	assert.Zero(t, k.Lag())
+
+	// The PONG is consumed.
+	var m Message
+	assert.NoError(t, k.Decode(&m))
+	assert.Equal(t, "PRIVMSG", m.Command)
+
+	assert.True(t, k.Lag() >= 5*time.Millisecond, "lag: %v", k.Lag())
+}
+
+func TestKeepaliveTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	k := NewKeepaliveConn(NewBaseConn(client), KeepaliveOptions{
+		Interval: 10 * time.Millisecond,
+		Timeout:  10 * time.Millisecond,
+	})
+	defer k.Close()
+
+	s := NewBaseConn(server)
+	defer s.Close()
+
+	go func() {
+		var m Message
+		for s.Decode(&m) == nil {
+		}
+	}()
+
+	var m Message
+	err := k.Decode(&m)
+
+	var timeoutErr *PingTimeoutError
+	assert.True(t, errors.As(err, &timeoutErr), "error: %v", err)
+
+	var netErr net.Error
+	assert.True(t, errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func TestKeepaliveServerPing(t *testing.T) {
+	client, server := net.Pipe()
+	k := NewKeepaliveConn(NewBaseConn(client), KeepaliveOptions{})
+	defer k.Close()
+
+	s := NewBaseConn(server)
+	defer s.Close()
+
+	go s.Encode(&Message{Command: "PING", Trailing: "abc"}) //nolint:errcheck
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var m Message
+		assert.NoError(t, k.Decode(&m))
+		assert.Equal(t, "PING", m.Command)
+	}()
+
+	var m Message
+	assert.NoError(t, s.Decode(&m))
+	assert.Equal(t, "PONG", m.Command)
+	assert.Equal(t, "abc", m.Trailing)
+
+	<-done
+}
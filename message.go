@@ -1,5 +1,7 @@
 package irc
 
+import "time"
+
 // Message is an IRC message.
 type Message struct {
 	Tags           map[string]string
@@ -16,6 +18,38 @@ type Message struct {
 	// message are references to parts of this string, meaning that the
 	// lifetime of this string is just as long as the message as a whole.
 	Raw string
+
+	// Received is the time the message was received, set by the Conn which
+	// decoded it. It is not used for encoding.
+	Received time.Time
+}
+
+// AllParams returns the message's parameters, including the trailing
+// parameter if there is one.
+func (m *Message) AllParams() []string {
+	if m.Trailing == "" && !m.ForcedTrailing {
+		return m.Params
+	}
+	return append(m.Params[:len(m.Params):len(m.Params)], m.Trailing)
+}
+
+// clone returns a copy of the message which shares nothing mutable with
+// it, for holding on to a message after the caller may have reused it.
+func (m *Message) clone() *Message {
+	c := *m
+
+	if m.Params != nil {
+		c.Params = append([]string(nil), m.Params...)
+	}
+
+	if m.Tags != nil {
+		c.Tags = make(map[string]string, len(m.Tags))
+		for k, v := range m.Tags {
+			c.Tags[k] = v
+		}
+	}
+
+	return &c
 }
 
 // Prefix is an IRC prefix.
@@ -114,6 +114,6 @@ func BenchmarkEncodeString(b *testing.B) {
 	b.SetBytes(int64(len(rawTwitch)))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		m.String()
+		_ = m.String()
 	}
 }
@@ -150,28 +150,38 @@ func (m *Message) parsePrefix(raw string) (string, error) {
 	prefix := raw[:i]
 	raw = raw[i+1:]
 
+	m.Prefix = ParsePrefix(prefix)
+
+	// <SPACE> can be many spaces, but the above stopped at the first space,
+	// so trim off any other spaces.
+	raw = strings.TrimLeftFunc(raw, isSpace)
+
+	return raw, nil
+}
+
+// ParsePrefix parses a prefix of the form nick!user@host, without the
+// leading colon. The user and host are optional.
+func ParsePrefix(prefix string) Prefix {
+	var p Prefix
+
 	user := strings.IndexByte(prefix, '!')
 	host := strings.IndexByte(prefix, '@')
 
 	if user > 0 && host > user {
-		m.Prefix.Name = prefix[:user]
-		m.Prefix.User = prefix[user+1 : host]
-		m.Prefix.Host = prefix[host+1:]
+		p.Name = prefix[:user]
+		p.User = prefix[user+1 : host]
+		p.Host = prefix[host+1:]
 	} else if user > 0 {
-		m.Prefix.Name = prefix[:user]
-		m.Prefix.User = prefix[user+1:]
+		p.Name = prefix[:user]
+		p.User = prefix[user+1:]
 	} else if host > 0 {
-		m.Prefix.Name = prefix[:host]
-		m.Prefix.Host = prefix[host+1:]
+		p.Name = prefix[:host]
+		p.Host = prefix[host+1:]
 	} else {
-		m.Prefix.Name = prefix
+		p.Name = prefix
 	}
 
-	// <SPACE> can be many spaces, but the above stopped at the first space,
-	// so trim off any other spaces.
-	raw = strings.TrimLeftFunc(raw, isSpace)
-
-	return raw, nil
+	return p
 }
 
 func (m *Message) parseCommand(raw string) (string, error) {
@@ -195,7 +205,17 @@ func (m *Message) parseCommand(raw string) (string, error) {
 }
 
 func (m *Message) parseParamsAndTrailing(raw string) {
-	if i := strings.IndexByte(raw, ':'); i != -1 {
+	// The trailing param starts with a colon, but colons may appear within
+	// middle params (for example, "CHANLIMIT=#:120" or an IPv6 host), so
+	// only a colon at the start of a param counts.
+	i := -1
+	if raw[0] == ':' {
+		i = 0
+	} else if j := strings.Index(raw, " :"); j != -1 {
+		i = j + 1
+	}
+
+	if i != -1 {
 		m.Trailing = raw[i+1:]
 		raw = raw[:i]
 
@@ -316,6 +316,34 @@ func TestParseEncodeGood(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, expected, m)
 	})
+
+	t.Run("colon in middle param", func(t *testing.T) {
+		raw := ":irc.example.org 005 jake CHANLIMIT=#:120 MAXLIST=b:60 :are supported by this server"
+		expected := &Message{
+			Prefix:   Prefix{Name: "irc.example.org"},
+			Command:  "005",
+			Params:   []string{"jake", "CHANLIMIT=#:120", "MAXLIST=b:60"},
+			Trailing: "are supported by this server",
+			Raw:      raw,
+		}
+
+		m, err := ParseMessage(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, m)
+	})
+
+	t.Run("trailing only", func(t *testing.T) {
+		raw := "PING :a:b"
+		expected := &Message{
+			Command:  "PING",
+			Trailing: "a:b",
+			Raw:      raw,
+		}
+
+		m, err := ParseMessage(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, m)
+	})
 }
 
 func TestParseError(t *testing.T) {
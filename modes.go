@@ -0,0 +1,64 @@
+package irc
+
+import "strings"
+
+// ModeChange is a single change from a MODE message.
+type ModeChange struct {
+	// Add is true if the mode was set, and false if it was unset.
+	Add bool
+
+	// Mode is the mode character.
+	Mode byte
+
+	// Param is the mode's parameter, if it takes one.
+	Param string
+}
+
+// ParseChannelModes parses the mode string and parameters of a channel
+// MODE message or RPL_CHANNELMODEIS reply (the parameters after the
+// channel name), using CHANMODES and PREFIX from is to determine which modes
+// take parameters. Modes missing a required parameter are dropped.
+func ParseChannelModes(is *ISupport, params []string) []ModeChange {
+	if len(params) == 0 {
+		return nil
+	}
+
+	a, b, c, _ := is.ChanModes()
+	prefixModes, _ := is.Prefix()
+
+	var changes []ModeChange
+	args := params[1:]
+	add := true
+
+	for i := 0; i < len(params[0]); i++ {
+		mode := params[0][i]
+
+		switch mode {
+		case '+':
+			add = true
+			continue
+		case '-':
+			add = false
+			continue
+		}
+
+		hasParam := strings.IndexByte(a, mode) != -1 ||
+			strings.IndexByte(b, mode) != -1 ||
+			strings.IndexByte(prefixModes, mode) != -1 ||
+			(add && strings.IndexByte(c, mode) != -1)
+
+		change := ModeChange{Add: add, Mode: mode}
+
+		if hasParam {
+			if len(args) == 0 {
+				continue
+			}
+			change.Param = args[0]
+			args = args[1:]
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
@@ -0,0 +1,88 @@
+package irc
+
+import "strings"
+
+// Channel types, as sent in RPL_NAMREPLY.
+const (
+	ChannelPublic  = '='
+	ChannelPrivate = '*'
+	ChannelSecret  = '@'
+)
+
+// Names is a parsed RPL_NAMREPLY. A channel's members may be split over
+// several replies.
+type Names struct {
+	Channel string
+
+	// Type is the channel's type: ChannelPublic, ChannelPrivate or
+	// ChannelSecret.
+	Type byte
+
+	Members []NamesMember
+}
+
+// NamesMember is a channel member listed in RPL_NAMREPLY.
+type NamesMember struct {
+	Nick string
+
+	// User and Host are only sent with userhost-in-names.
+	User string
+	Host string
+
+	// Prefixes are the member's prefixes (such as "@" for operator), in
+	// order of rank, so the highest is first. Without multi-prefix, only
+	// the highest is sent.
+	Prefixes string
+
+	// Modes are the membership modes matching Prefixes, for example "ov".
+	Modes string
+}
+
+// ParseNames parses an RPL_NAMREPLY message, using PREFIX from is to decode
+// the members' prefixes. ok is false if m is not a well-formed
+// RPL_NAMREPLY.
+func ParseNames(m *Message, is *ISupport) (names *Names, ok bool) {
+	if m.Command != RPL_NAMREPLY {
+		return nil, false
+	}
+
+	// <client> <symbol> <channel> :[prefix]<nick>{ [prefix]<nick>}
+	params := m.AllParams()
+	if len(params) < 4 || len(params[1]) != 1 {
+		return nil, false
+	}
+
+	names = &Names{
+		Channel: params[2],
+		Type:    params[1][0],
+	}
+
+	modes, prefixes := is.Prefix()
+
+	for _, entry := range strings.Fields(params[3]) {
+		i := 0
+		for i < len(entry) && strings.IndexByte(prefixes, entry[i]) != -1 {
+			i++
+		}
+
+		p := ParsePrefix(entry[i:])
+		if p.Name == "" {
+			continue
+		}
+
+		member := NamesMember{
+			Nick:     p.Name,
+			User:     p.User,
+			Host:     p.Host,
+			Prefixes: sortPrefixes(entry[:i], prefixes),
+		}
+
+		for j := 0; j < len(member.Prefixes); j++ {
+			member.Modes += string(modes[strings.IndexByte(prefixes, member.Prefixes[j])])
+		}
+
+		names.Members = append(names.Members, member)
+	}
+
+	return names, true
+}
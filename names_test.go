@@ -0,0 +1,35 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNames(t *testing.T) {
+	is := &ISupport{}
+	is.Update(&Message{Command: RPL_ISUPPORT, Params: []string{"me", "PREFIX=(qaohv)~&@%+"}})
+
+	m, err := ParseMessage(":irc.example.org 353 me @ #chan :+@alice!a@a.org ~bob carol!c@c.org")
+	assert.NoError(t, err)
+
+	names, ok := ParseNames(m, is)
+	assert.True(t, ok)
+	assert.Equal(t, &Names{
+		Channel: "#chan",
+		Type:    ChannelSecret,
+		Members: []NamesMember{
+			{Nick: "alice", User: "a", Host: "a.org", Prefixes: "@+", Modes: "ov"},
+			{Nick: "bob", Prefixes: "~", Modes: "q"},
+			{Nick: "carol", User: "c", Host: "c.org"},
+		},
+	}, names)
+
+	m, err = ParseMessage(":irc.example.org 353 me #chan :alice")
+	assert.NoError(t, err)
+	_, ok = ParseNames(m, is)
+	assert.False(t, ok)
+
+	_, ok = ParseNames(&Message{Command: RPL_ENDOFNAMES}, is)
+	assert.False(t, ok)
+}
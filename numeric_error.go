@@ -0,0 +1,149 @@
+package irc
+
+import "errors"
+
+// Sentinel errors for common numeric errors, which a *NumericError with the
+// matching code wraps, so that they can be checked with errors.Is:
+//
+//	if errors.Is(err, irc.ErrBannedFromChan) {
+//		...
+//	}
+var (
+	ErrNoSuchNick       = errors.New("irc: no such nick")
+	ErrNoSuchServer     = errors.New("irc: no such server")
+	ErrNoSuchChannel    = errors.New("irc: no such channel")
+	ErrCannotSendToChan = errors.New("irc: cannot send to channel")
+	ErrTooManyChannels  = errors.New("irc: too many channels")
+	ErrTooManyTargets   = errors.New("irc: too many targets")
+	ErrNoRecipient      = errors.New("irc: no recipient")
+	ErrNoTextToSend     = errors.New("irc: no text to send")
+	ErrUnknownCommand   = errors.New("irc: unknown command")
+	ErrErroneusNickname = errors.New("irc: erroneous nickname")
+	ErrNicknameInUse    = errors.New("irc: nickname in use")
+	ErrUserNotInChannel = errors.New("irc: user not in channel")
+	ErrNotOnChannel     = errors.New("irc: not on channel")
+	ErrUserOnChannel    = errors.New("irc: user already on channel")
+	ErrNeedMoreParams   = errors.New("irc: need more params")
+	ErrChannelIsFull    = errors.New("irc: channel is full")
+	ErrInviteOnlyChan   = errors.New("irc: channel is invite only")
+	ErrBannedFromChan   = errors.New("irc: banned from channel")
+	ErrBadChannelKey    = errors.New("irc: bad channel key")
+	ErrBadChanMask      = errors.New("irc: bad channel mask")
+	ErrNoPrivileges     = errors.New("irc: no privileges")
+	ErrChanOPrivsNeeded = errors.New("irc: channel operator privileges needed")
+)
+
+type numericInfo struct {
+	name     string
+	sentinel error
+}
+
+var numericErrors = map[string]numericInfo{
+	ERR_NOSUCHNICK:       {"ERR_NOSUCHNICK", ErrNoSuchNick},
+	ERR_NOSUCHSERVER:     {"ERR_NOSUCHSERVER", ErrNoSuchServer},
+	ERR_NOSUCHCHANNEL:    {"ERR_NOSUCHCHANNEL", ErrNoSuchChannel},
+	ERR_CANNOTSENDTOCHAN: {"ERR_CANNOTSENDTOCHAN", ErrCannotSendToChan},
+	ERR_TOOMANYCHANNELS:  {"ERR_TOOMANYCHANNELS", ErrTooManyChannels},
+	ERR_TOOMANYTARGETS:   {"ERR_TOOMANYTARGETS", ErrTooManyTargets},
+	ERR_NORECIPIENT:      {"ERR_NORECIPIENT", ErrNoRecipient},
+	ERR_NOTEXTTOSEND:     {"ERR_NOTEXTTOSEND", ErrNoTextToSend},
+	ERR_TOOMANYMATCHES:   {"ERR_TOOMANYMATCHES", nil},
+	ERR_UNKNOWNCOMMAND:   {"ERR_UNKNOWNCOMMAND", ErrUnknownCommand},
+	ERR_NOMOTD:           {"ERR_NOMOTD", nil},
+	ERR_NONICKNAMEGIVEN:  {"ERR_NONICKNAMEGIVEN", nil},
+	ERR_ERRONEUSNICKNAME: {"ERR_ERRONEUSNICKNAME", ErrErroneusNickname},
+	ERR_NICKNAMEINUSE:    {"ERR_NICKNAMEINUSE", ErrNicknameInUse},
+	ERR_NICKCOLLISION:    {"ERR_NICKCOLLISION", nil},
+	ERR_UNAVAILRESOURCE:  {"ERR_UNAVAILRESOURCE", nil},
+	ERR_USERNOTINCHANNEL: {"ERR_USERNOTINCHANNEL", ErrUserNotInChannel},
+	ERR_NOTONCHANNEL:     {"ERR_NOTONCHANNEL", ErrNotOnChannel},
+	ERR_USERONCHANNEL:    {"ERR_USERONCHANNEL", ErrUserOnChannel},
+	ERR_NEEDMOREPARAMS:   {"ERR_NEEDMOREPARAMS", ErrNeedMoreParams},
+	ERR_PASSWDMISMATCH:   {"ERR_PASSWDMISMATCH", nil},
+	ERR_YOUREBANNEDCREEP: {"ERR_YOUREBANNEDCREEP", nil},
+	ERR_CHANNELISFULL:    {"ERR_CHANNELISFULL", ErrChannelIsFull},
+	ERR_INVITEONLYCHAN:   {"ERR_INVITEONLYCHAN", ErrInviteOnlyChan},
+	ERR_BANNEDFROMCHAN:   {"ERR_BANNEDFROMCHAN", ErrBannedFromChan},
+	ERR_BADCHANNELKEY:    {"ERR_BADCHANNELKEY", ErrBadChannelKey},
+	ERR_BADCHANMASK:      {"ERR_BADCHANMASK", ErrBadChanMask},
+	ERR_NOPRIVILEGES:     {"ERR_NOPRIVILEGES", ErrNoPrivileges},
+	ERR_CHANOPRIVSNEEDED: {"ERR_CHANOPRIVSNEEDED", ErrChanOPrivsNeeded},
+	ERR_TOOMANYWATCH:     {"ERR_TOOMANYWATCH", nil},
+}
+
+// NumericError is an error numeric reply, in the range 400 to 599.
+type NumericError struct {
+	// Code is the numeric, for example ERR_BANNEDFROMCHAN.
+	Code string
+
+	// Name is the symbolic name of the numeric, for example
+	// "ERR_BANNEDFROMCHAN", or the empty string if it is not known.
+	Name string
+
+	// Target is the nick, channel or command the error is about, or the
+	// empty string if the reply has no such parameter.
+	Target string
+
+	// Text is the server's description of the error.
+	Text string
+}
+
+// ParseNumericError returns the error described by an error numeric reply,
+// or false if m is not one.
+func ParseNumericError(m *Message) (*NumericError, bool) {
+	if !IsNumericError(m.Command) {
+		return nil, false
+	}
+
+	// <client> [<target>...] :<text>
+	params := m.AllParams()
+
+	e := &NumericError{
+		Code: m.Command,
+		Name: numericErrors[m.Command].name,
+	}
+
+	if len(params) > 0 {
+		e.Text = params[len(params)-1]
+	}
+
+	if len(params) > 2 {
+		e.Target = params[1]
+	}
+
+	return e, true
+}
+
+// IsNumericError returns true if command is a numeric in the error range,
+// 400 to 599.
+func IsNumericError(command string) bool {
+	if len(command) != 3 {
+		return false
+	}
+
+	for i := 0; i < 3; i++ {
+		if command[i] < '0' || command[i] > '9' {
+			return false
+		}
+	}
+
+	return command[0] == '4' || command[0] == '5'
+}
+
+func (e *NumericError) Error() string {
+	name := e.Name
+	if name == "" {
+		name = e.Code
+	}
+
+	if e.Target == "" {
+		return "irc: " + name + ": " + e.Text
+	}
+	return "irc: " + name + " " + e.Target + ": " + e.Text
+}
+
+// Unwrap returns the sentinel error for the numeric, such as
+// ErrBannedFromChan, or nil if there is none.
+func (e *NumericError) Unwrap() error {
+	return numericErrors[e.Code].sentinel
+}
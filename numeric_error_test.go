@@ -0,0 +1,35 @@
+package irc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNumericError(t *testing.T) {
+	m, err := ParseMessage(":irc.example.org 474 me #chan :Cannot join channel (+b)")
+	assert.NoError(t, err)
+
+	numErr, ok := ParseNumericError(m)
+	assert.True(t, ok)
+	assert.Equal(t, &NumericError{Code: ERR_BANNEDFROMCHAN, Name: "ERR_BANNEDFROMCHAN", Target: "#chan", Text: "Cannot join channel (+b)"}, numErr)
+	assert.Equal(t, "irc: ERR_BANNEDFROMCHAN #chan: Cannot join channel (+b)", numErr.Error())
+
+	err = numErr
+	assert.True(t, errors.Is(err, ErrBannedFromChan))
+	assert.False(t, errors.Is(err, ErrBadChannelKey))
+
+	m, err = ParseMessage(":irc.example.org 599 me :Something went wrong")
+	assert.NoError(t, err)
+
+	numErr, ok = ParseNumericError(m)
+	assert.True(t, ok)
+	assert.Equal(t, "irc: 599: Something went wrong", numErr.Error())
+	assert.Nil(t, numErr.Unwrap())
+
+	for _, command := range []string{"001", "366", "600", "PRIVMSG", "4000"} {
+		_, ok := ParseNumericError(&Message{Command: command})
+		assert.False(t, ok, command)
+	}
+}
@@ -0,0 +1,328 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+var (
+	// ErrProxyAuth is returned when a proxy rejects the provided
+	// credentials, or requires credentials which were not provided.
+	ErrProxyAuth = errors.New("irc: proxy authentication failed")
+
+	errSOCKS5Version     = errors.New("irc: proxy is not a socks5 proxy")
+	errSOCKS5AuthVersion = errors.New("irc: socks5 proxy: unsupported authentication version")
+)
+
+// SOCKS5Error is returned when a SOCKS5 proxy fails a connection request.
+type SOCKS5Error struct {
+	// Code is the reply code sent by the proxy.
+	Code byte
+}
+
+var socks5Errors = [...]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "ttl expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+func (e *SOCKS5Error) Error() string {
+	if int(e.Code) < len(socks5Errors) && socks5Errors[e.Code] != "" {
+		return "irc: socks5 proxy: " + socks5Errors[e.Code]
+	}
+	return "irc: socks5 proxy: unknown error " + strconv.Itoa(int(e.Code))
+}
+
+// SOCKS5Dialer dials connections through a SOCKS5 proxy (RFC 1928), such as
+// the one provided by Tor. Host names are sent to the proxy unresolved, so
+// the proxy performs DNS resolution.
+type SOCKS5Dialer struct {
+	// Addr is the address of the proxy.
+	Addr string
+
+	// Username and Password, if Username is not empty, are used for
+	// username/password authentication (RFC 1929).
+	Username string
+	Password string
+
+	// Dialer is used to connect to the proxy. If nil, a zero net.Dialer is
+	// used.
+	Dialer Dialer
+}
+
+var _ Dialer = (*SOCKS5Dialer)(nil)
+
+const (
+	socks5Version         = 5
+	socks5AuthNone        = 0
+	socks5AuthPassword    = 2
+	socks5PasswordVersion = 1
+	socks5Connect         = 1
+	socks5IPv4            = 1
+	socks5Domain          = 3
+	socks5IPv6            = 4
+)
+
+// DialContext connects to addr through the proxy. Only TCP is supported.
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, errors.New("irc: socks5 proxy: unsupported network " + network)
+	}
+
+	req, err := socks5Request(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := forwardDialer(d.Dialer).DialContext(ctx, "tcp", d.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	err = handshake(ctx, conn, func() error {
+		return d.handshake(conn, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (d *SOCKS5Dialer) handshake(conn net.Conn, req []byte) error {
+	greeting := []byte{socks5Version, 1, socks5AuthNone}
+	if d.Username != "" {
+		greeting = []byte{socks5Version, 2, socks5AuthNone, socks5AuthPassword}
+	}
+
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	var buf [4]byte
+
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return err
+	}
+
+	if buf[0] != socks5Version {
+		return errSOCKS5Version
+	}
+
+	switch buf[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if err := d.authenticate(conn); err != nil {
+			return err
+		}
+	default:
+		return ErrProxyAuth
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return err
+	}
+
+	if buf[0] != socks5Version {
+		return errSOCKS5Version
+	}
+
+	if buf[1] != 0 {
+		return &SOCKS5Error{Code: buf[1]}
+	}
+
+	// Discard the bound address, which is of no use to the client.
+	var skip int
+	switch buf[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return err
+		}
+		skip = int(buf[0])
+	default:
+		return &SOCKS5Error{Code: 8}
+	}
+
+	_, err := io.CopyN(io.Discard, conn, int64(skip+2))
+	return err
+}
+
+func (d *SOCKS5Dialer) authenticate(conn net.Conn) error {
+	if len(d.Username) > 255 || len(d.Password) > 255 {
+		return errors.New("irc: socks5 proxy: username or password too long")
+	}
+
+	b := make([]byte, 0, 3+len(d.Username)+len(d.Password))
+	b = append(b, socks5PasswordVersion, byte(len(d.Username)))
+	b = append(b, d.Username...)
+	b = append(b, byte(len(d.Password)))
+	b = append(b, d.Password...)
+
+	if _, err := conn.Write(b); err != nil {
+		return err
+	}
+
+	var buf [2]byte
+	if _, err := io.ReadFull(conn, buf[:]); err != nil {
+		return err
+	}
+
+	// RFC 1929 replies with the version of the subnegotiation, not of
+	// SOCKS itself.
+	if buf[0] != socks5PasswordVersion {
+		return errSOCKS5AuthVersion
+	}
+
+	if buf[1] != 0 {
+		return ErrProxyAuth
+	}
+
+	return nil
+}
+
+func socks5Request(addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, errors.New("irc: socks5 proxy: invalid port " + portStr)
+	}
+
+	req := []byte{socks5Version, socks5Connect, 0}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5IPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5IPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, errors.New("irc: socks5 proxy: host name too long")
+		}
+		req = append(req, socks5Domain, byte(len(host)))
+		req = append(req, host...)
+	}
+
+	return append(req, byte(port>>8), byte(port)), nil
+}
+
+// HTTPProxyDialer dials connections through an HTTP proxy using the CONNECT
+// method. To connect to the proxy itself over TLS, set Dialer to a
+// TLSDialer.
+type HTTPProxyDialer struct {
+	// Addr is the address of the proxy.
+	Addr string
+
+	// Username and Password, if Username is not empty, are sent using basic
+	// authentication.
+	Username string
+	Password string
+
+	// Header contains additional headers to send with the request.
+	Header http.Header
+
+	// Dialer is used to connect to the proxy. If nil, a zero net.Dialer is
+	// used.
+	Dialer Dialer
+}
+
+var _ Dialer = (*HTTPProxyDialer)(nil)
+
+// DialContext connects to addr through the proxy.
+func (d *HTTPProxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := forwardDialer(d.Dialer).DialContext(ctx, network, d.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var br *bufio.Reader
+
+	err = handshake(ctx, conn, func() error {
+		var err error
+		br, err = d.handshake(conn, addr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+
+	return conn, nil
+}
+
+func (d *HTTPProxyDialer) handshake(conn net.Conn, addr string) (*bufio.Reader, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	for k, v := range d.Header {
+		req.Header[k] = v
+	}
+
+	if d.Username != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(d.Username + ":" + d.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	if _, err := fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr); err != nil {
+		return nil, err
+	}
+
+	if err := req.Header.Write(conn); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(conn, "\r\n"); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return nil, ErrProxyAuth
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, errors.New("irc: http proxy: " + resp.Status)
+	}
+
+	return br, nil
+}
@@ -0,0 +1,333 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ircServer accepts a single connection on a listener and sends it a PING.
+func ircServer(l net.Listener) {
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		NewBaseConn(conn).Encode(&Message{Command: "PING", Trailing: "proxied"}) //nolint:errcheck
+	}()
+}
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	return l
+}
+
+func assertPing(t *testing.T, conn *BaseConn) {
+	defer assertClose(t, conn)
+
+	var m Message
+	assert.NoError(t, conn.Decode(&m))
+	assert.Equal(t, "PING", m.Command)
+	assert.Equal(t, "proxied", m.Trailing)
+}
+
+// socks5Proxy is a minimal SOCKS5 proxy, which accepts a single connection.
+// The target of the CONNECT is sent on the returned channel.
+func socks5Proxy(t *testing.T, user, pass string, reply byte) (net.Listener, <-chan string) {
+	l := listen(t)
+	targets := make(chan string, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+
+		var hdr [2]byte
+		io.ReadFull(r, hdr[:]) //nolint:errcheck
+		methods := make([]byte, hdr[1])
+		io.ReadFull(r, methods) //nolint:errcheck
+
+		if user == "" {
+			conn.Write([]byte{5, 0}) //nolint:errcheck
+		} else {
+			conn.Write([]byte{5, 2}) //nolint:errcheck
+
+			var b [1]byte
+			io.ReadFull(r, b[:]) //nolint:errcheck
+			io.ReadFull(r, b[:]) //nolint:errcheck
+			u := make([]byte, b[0])
+			io.ReadFull(r, u)    //nolint:errcheck
+			io.ReadFull(r, b[:]) //nolint:errcheck
+			p := make([]byte, b[0])
+			io.ReadFull(r, p) //nolint:errcheck
+
+			if string(u) != user || string(p) != pass {
+				conn.Write([]byte{1, 1}) //nolint:errcheck
+				return
+			}
+			conn.Write([]byte{1, 0}) //nolint:errcheck
+		}
+
+		var req [4]byte
+		io.ReadFull(r, req[:]) //nolint:errcheck
+
+		var host string
+		switch req[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(r, ip) //nolint:errcheck
+			host = net.IP(ip).String()
+		case 3:
+			var b [1]byte
+			io.ReadFull(r, b[:]) //nolint:errcheck
+			h := make([]byte, b[0])
+			io.ReadFull(r, h) //nolint:errcheck
+			host = string(h)
+		}
+
+		var port uint16
+		binary.Read(r, binary.BigEndian, &port) //nolint:errcheck
+
+		target := net.JoinHostPort(host, strconv.Itoa(int(port)))
+		targets <- target
+
+		if reply != 0 {
+			conn.Write([]byte{5, reply, 0, 1, 0, 0, 0, 0, 0, 0}) //nolint:errcheck
+			return
+		}
+
+		if host == "irc.example.org" {
+			target = net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port)))
+		}
+
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) //nolint:errcheck
+			return
+		}
+		defer upstream.Close()
+
+		conn.Write([]byte{5, 0, 0, 3, 4, 'h', 'o', 's', 't', 0, 0}) //nolint:errcheck
+
+		go io.Copy(upstream, r) //nolint:errcheck
+		io.Copy(conn, upstream) //nolint:errcheck
+	}()
+
+	return l, targets
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	server := listen(t)
+	defer server.Close()
+	ircServer(server)
+
+	proxy, targets := socks5Proxy(t, "", "", 0)
+	defer proxy.Close()
+
+	d := &SOCKS5Dialer{Addr: proxy.Addr().String()}
+	conn, err := BaseDialContext(context.Background(), d, server.Addr().String())
+	assert.NoError(t, err)
+	assert.Equal(t, server.Addr().String(), <-targets)
+
+	assertPing(t, conn)
+}
+
+func TestSOCKS5DialerAuth(t *testing.T) {
+	server := listen(t)
+	defer server.Close()
+	ircServer(server)
+
+	proxy, targets := socks5Proxy(t, "user", "hunter2", 0)
+	defer proxy.Close()
+
+	_, serverPort, err := net.SplitHostPort(server.Addr().String())
+	assert.NoError(t, err)
+
+	// Host names are resolved by the proxy.
+	addr := net.JoinHostPort("irc.example.org", serverPort)
+
+	d := &SOCKS5Dialer{Addr: proxy.Addr().String(), Username: "user", Password: "hunter2"}
+	conn, err := BaseDialContext(context.Background(), d, addr)
+	assert.NoError(t, err)
+	assert.Equal(t, addr, <-targets)
+
+	assertPing(t, conn)
+
+	proxy, _ = socks5Proxy(t, "user", "hunter2", 0)
+	defer proxy.Close()
+
+	d = &SOCKS5Dialer{Addr: proxy.Addr().String(), Username: "user", Password: "wrong"}
+	_, err = d.DialContext(context.Background(), "tcp", addr)
+	assert.Equal(t, ErrProxyAuth, err)
+}
+
+func TestSOCKS5DialerAuthVersion(t *testing.T) {
+	// A proxy which answers the subnegotiation with the SOCKS version.
+	proxy := listen(t)
+	defer proxy.Close()
+
+	go func() {
+		conn, err := proxy.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var hdr [3]byte
+		io.ReadFull(conn, hdr[:])  //nolint:errcheck
+		conn.Write([]byte{5, 2})   //nolint:errcheck
+		io.ReadFull(conn, hdr[:2]) //nolint:errcheck
+		conn.Write([]byte{5, 0})   //nolint:errcheck
+	}()
+
+	d := &SOCKS5Dialer{Addr: proxy.Addr().String(), Username: "u", Password: "p"}
+	_, err := d.DialContext(context.Background(), "tcp", "127.0.0.1:6667")
+	assert.Equal(t, errSOCKS5AuthVersion, err)
+}
+
+func TestSOCKS5DialerError(t *testing.T) {
+	proxy, _ := socks5Proxy(t, "", "", 5)
+	defer proxy.Close()
+
+	d := &SOCKS5Dialer{Addr: proxy.Addr().String()}
+	_, err := d.DialContext(context.Background(), "tcp", "127.0.0.1:6667")
+	assert.Equal(t, &SOCKS5Error{Code: 5}, err)
+	assert.EqualError(t, err, "irc: socks5 proxy: connection refused")
+}
+
+func TestSOCKS5DialerTimeout(t *testing.T) {
+	// A proxy which accepts but never responds.
+	proxy := listen(t)
+	defer proxy.Close()
+
+	go func() {
+		conn, err := proxy.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn) //nolint:errcheck
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	d := &SOCKS5Dialer{Addr: proxy.Addr().String()}
+	_, err := d.DialContext(ctx, "tcp", "127.0.0.1:6667")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+// httpProxy is a minimal HTTP CONNECT proxy, which requires the given
+// credentials if user is not empty.
+func httpProxy(t *testing.T, user, pass string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if user != "" {
+			u, p, ok := parseProxyAuth(r)
+			if !ok || u != user || p != pass {
+				w.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+		}
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Write the response and the start of the upstream's data together,
+		// to check that data buffered by the client is not lost.
+		var first [64]byte
+		n, _ := upstream.Read(first[:])
+
+		brw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n") //nolint:errcheck
+		brw.Write(first[:n])                                           //nolint:errcheck
+		brw.Flush()                                                    //nolint:errcheck
+
+		go io.Copy(upstream, conn) //nolint:errcheck
+		io.Copy(conn, upstream)    //nolint:errcheck
+	}))
+}
+
+func parseProxyAuth(r *http.Request) (user, pass string, ok bool) {
+	r2 := &http.Request{Header: http.Header{"Authorization": r.Header["Proxy-Authorization"]}}
+	return r2.BasicAuth()
+}
+
+func TestHTTPProxyDialer(t *testing.T) {
+	server := listen(t)
+	defer server.Close()
+	ircServer(server)
+
+	proxy := httpProxy(t, "user", "hunter2")
+	defer proxy.Close()
+
+	d := &HTTPProxyDialer{
+		Addr:     proxy.Listener.Addr().String(),
+		Username: "user",
+		Password: "hunter2",
+	}
+
+	conn, err := BaseDialContext(context.Background(), d, server.Addr().String())
+	assert.NoError(t, err)
+	assertPing(t, conn)
+
+	d.Password = "wrong"
+	_, err = d.DialContext(context.Background(), "tcp", server.Addr().String())
+	assert.Equal(t, ErrProxyAuth, err)
+}
+
+func TestTLSDialerOverProxy(t *testing.T) {
+	// Borrow httptest's certificate, which is valid for example.com.
+	certServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certServer.Close()
+
+	server, err := tls.Listen("tcp", "127.0.0.1:0", certServer.TLS)
+	assert.NoError(t, err)
+	defer server.Close()
+	ircServer(server)
+
+	proxy, _ := socks5Proxy(t, "", "", 0)
+	defer proxy.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certServer.Certificate())
+
+	d := &TLSDialer{
+		Dialer: &SOCKS5Dialer{Addr: proxy.Addr().String()},
+		Config: &tls.Config{RootCAs: roots, ServerName: "example.com"},
+	}
+
+	conn, err := BaseDialContext(context.Background(), d, server.Addr().String())
+	assert.NoError(t, err)
+	assertPing(t, conn)
+}
@@ -0,0 +1,423 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is returned by Queue.Encode when a message is dropped
+	// because the queue is full.
+	ErrQueueFull = errors.New("irc: queue full")
+
+	// ErrQueueClosed is returned by Queue.Encode after the queue has been
+	// closed.
+	ErrQueueClosed = errors.New("irc: queue closed")
+)
+
+// Priority is the priority class of an outgoing message. Lower values are
+// sent first.
+type Priority int
+
+// Priority classes, from highest to lowest.
+const (
+	// PriorityControl is for connection control traffic, such as PONG,
+	// which must not wait behind anything else.
+	PriorityControl Priority = iota
+
+	// PriorityReply is for user-facing traffic.
+	PriorityReply
+
+	// PriorityBulk is for large amounts of output which can wait.
+	PriorityBulk
+
+	numPriorities = iota
+)
+
+// DropPolicy decides which message is dropped when a Queue is full.
+type DropPolicy int
+
+const (
+	// DropNewest rejects the message being queued.
+	DropNewest DropPolicy = iota
+
+	// DropOldest drops the oldest message of the lowest priority class that
+	// is not more important than the message being queued. If every queued
+	// message is more important, the new message is rejected.
+	DropOldest
+)
+
+// QueueOptions configures a Queue.
+type QueueOptions struct {
+	// MaxLen is the maximum number of messages held by the queue. Control
+	// messages are never dropped and do not count towards the limit. If
+	// zero, the queue is unbounded.
+	MaxLen int
+
+	// Drop is the policy used once MaxLen is reached.
+	Drop DropPolicy
+
+	// Classify assigns a priority to a message passed to Encode. If nil,
+	// DefaultClassify is used.
+	Classify func(*Message) Priority
+
+	// OnDrop, if set, is called with each message dropped from the queue.
+	// It is called with the queue locked, so must not call into the queue.
+	OnDrop func(*Message)
+
+	// ISupport, if set, provides the casemapping used to compare targets,
+	// so that differently cased names for one channel share a queue. It may
+	// be updated after the queue is created. If nil, the rfc1459
+	// casemapping is used.
+	ISupport *ISupport
+}
+
+var controlCommands = map[string]bool{
+	"PING":         true,
+	"PONG":         true,
+	"QUIT":         true,
+	"CAP":          true,
+	"AUTHENTICATE": true,
+	"PASS":         true,
+	"NICK":         true,
+	"USER":         true,
+}
+
+// DefaultClassify sends registration and connection control commands (such
+// as PING, PONG, QUIT and CAP) as PriorityControl, and everything else as
+// PriorityReply. Bulk messages should be sent with EncodePriority.
+func DefaultClassify(m *Message) Priority {
+	if controlCommands[strings.ToUpper(m.Command)] {
+		return PriorityControl
+	}
+	return PriorityReply
+}
+
+// Queue is an Encoder which buffers outgoing messages and sends them to
+// another Encoder (typically a FloodControl) in priority order. Within
+// a priority class, messages are sent round-robin by target, so one busy
+// channel cannot starve the others.
+//
+// Encode never blocks on the wrapped Encoder, and copies the message, so it
+// may be reused. If the wrapped Encoder returns an error, the queue stops,
+// and the error is returned by subsequent calls to Encode.
+type Queue struct {
+	e    Encoder
+	opts QueueOptions
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	classes [numPriorities]queueClass
+	length  int
+	seq     uint64
+	sending bool
+	idle    []chan struct{}
+	err     error
+	done    chan struct{}
+}
+
+var _ Encoder = (*Queue)(nil)
+
+// NewQueue creates a new Queue which sends to e, and starts its sending
+// goroutine. Close must be called to stop it.
+func NewQueue(e Encoder, opts QueueOptions) *Queue {
+	if opts.Classify == nil {
+		opts.Classify = DefaultClassify
+	}
+
+	q := &Queue{
+		e:    e,
+		opts: opts,
+		done: make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	go q.run()
+
+	return q
+}
+
+// Encode queues a message using the priority given by the Classify option.
+func (q *Queue) Encode(m *Message) error {
+	return q.EncodePriority(m, q.opts.Classify(m))
+}
+
+// EncodePriority queues a message with the given priority. The message is
+// copied, so the caller may reuse it once EncodePriority returns.
+func (q *Queue) EncodePriority(m *Message, p Priority) error {
+	m = m.clone()
+
+	if p < 0 {
+		p = 0
+	} else if p >= numPriorities {
+		p = numPriorities - 1
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.err != nil {
+		return q.err
+	}
+
+	if p != PriorityControl && q.opts.MaxLen > 0 && q.length >= q.opts.MaxLen {
+		if !q.dropFor(p) {
+			q.dropped(m)
+			return ErrQueueFull
+		}
+	}
+
+	q.seq++
+	q.classes[p].push(q.target(m), queued{m, q.seq})
+	if p != PriorityControl {
+		q.length++
+	}
+
+	q.cond.Signal()
+
+	return nil
+}
+
+// Len returns the number of messages waiting to be sent.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.length + q.classes[PriorityControl].len
+}
+
+// Flush waits until every queued message has been sent, or the context
+// ends. If the queue stops first, its error is returned.
+func (q *Queue) Flush(ctx context.Context) error {
+	q.mu.Lock()
+
+	if q.err != nil {
+		err := q.err
+		q.mu.Unlock()
+		return err
+	}
+
+	if q.empty() {
+		q.mu.Unlock()
+		return nil
+	}
+
+	idle := make(chan struct{})
+	q.idle = append(q.idle, idle)
+	q.mu.Unlock()
+
+	select {
+	case <-idle:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the queue, discarding any unsent messages. To send them
+// first (for example, a final QUIT), call Flush before Close. It does not
+// close the wrapped Encoder, but does wait for a message being sent to it,
+// so it blocks for as long as the wrapped Encoder does; closing the
+// underlying connection first unblocks it.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.err == nil {
+		q.err = ErrQueueClosed
+	}
+	q.cond.Broadcast()
+	q.notifyIdle()
+	q.mu.Unlock()
+
+	<-q.done
+	return nil
+}
+
+// empty returns true if no messages are queued or being sent. q.mu must be
+// held.
+func (q *Queue) empty() bool {
+	return !q.sending && q.length == 0 && q.classes[PriorityControl].len == 0
+}
+
+// notifyIdle wakes any callers of Flush. q.mu must be held.
+func (q *Queue) notifyIdle() {
+	for _, idle := range q.idle {
+		close(idle)
+	}
+	q.idle = nil
+}
+
+// dropFor makes room for a message of priority p according to the drop
+// policy, returning false if the new message should be rejected instead.
+func (q *Queue) dropFor(p Priority) bool {
+	if q.opts.Drop != DropOldest {
+		return false
+	}
+
+	for i := numPriorities - 1; i >= int(p); i-- {
+		if m := q.classes[i].popOldest(); m != nil {
+			q.length--
+			q.dropped(m)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q *Queue) dropped(m *Message) {
+	if q.opts.OnDrop != nil {
+		q.opts.OnDrop(m)
+	}
+}
+
+func (q *Queue) next() *Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.sending = false
+
+	for {
+		if q.err != nil {
+			q.notifyIdle()
+			return nil
+		}
+
+		for i := range q.classes {
+			if m := q.classes[i].pop(); m != nil {
+				if i != int(PriorityControl) {
+					q.length--
+				}
+				q.sending = true
+				return m
+			}
+		}
+
+		q.notifyIdle()
+		q.cond.Wait()
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	for {
+		m := q.next()
+		if m == nil {
+			return
+		}
+
+		if err := q.e.Encode(m); err != nil {
+			q.mu.Lock()
+			if q.err == nil {
+				q.err = err
+			}
+			q.sending = false
+			q.notifyIdle()
+			q.mu.Unlock()
+			return
+		}
+	}
+}
+
+// target returns the key used for fairness between targets. Messages
+// without a target share a single key.
+func (q *Queue) target(m *Message) string {
+	switch strings.ToUpper(m.Command) {
+	case "PRIVMSG", "NOTICE", "TAGMSG", "JOIN", "PART", "MODE", "TOPIC", "KICK", "NAMES", "WHO":
+		if len(m.Params) > 0 {
+			mapping := CaseMappingRFC1459
+			if q.opts.ISupport != nil {
+				mapping = q.opts.ISupport.CaseMapping()
+			}
+			return mapping.Fold(m.Params[0])
+		}
+	}
+	return ""
+}
+
+type queued struct {
+	m   *Message
+	seq uint64
+}
+
+// queueClass holds the messages of one priority class, as a FIFO per target
+// and a round-robin order of targets.
+type queueClass struct {
+	targets map[string][]queued
+	order   []string
+	len     int
+}
+
+func (c *queueClass) push(target string, q queued) {
+	if c.targets == nil {
+		c.targets = make(map[string][]queued)
+	}
+
+	msgs, ok := c.targets[target]
+	if !ok {
+		c.order = append(c.order, target)
+	}
+	c.targets[target] = append(msgs, q)
+	c.len++
+}
+
+// pop removes the first message of the next target in the round-robin,
+// moving that target to the back of the order if it has more messages.
+func (c *queueClass) pop() *Message {
+	if c.len == 0 {
+		return nil
+	}
+
+	target := c.order[0]
+	c.order = c.order[1:]
+
+	m := c.remove(target)
+	if _, ok := c.targets[target]; ok {
+		c.order = append(c.order, target)
+	}
+
+	return m
+}
+
+// popOldest removes the oldest message, leaving the round-robin order of
+// targets untouched unless the target has no more messages.
+func (c *queueClass) popOldest() *Message {
+	if c.len == 0 {
+		return nil
+	}
+
+	oldest := -1
+	for i, target := range c.order {
+		if oldest == -1 || c.targets[target][0].seq < c.targets[c.order[oldest]][0].seq {
+			oldest = i
+		}
+	}
+
+	target := c.order[oldest]
+	m := c.remove(target)
+	if _, ok := c.targets[target]; !ok {
+		c.order = append(c.order[:oldest], c.order[oldest+1:]...)
+	}
+
+	return m
+}
+
+func (c *queueClass) remove(target string) *Message {
+	msgs := c.targets[target]
+	m := msgs[0].m
+	msgs[0] = queued{}
+	msgs = msgs[1:]
+
+	if len(msgs) == 0 {
+		delete(c.targets, target)
+	} else {
+		c.targets[target] = msgs
+	}
+
+	c.len--
+	return m
+}
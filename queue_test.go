@@ -0,0 +1,240 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingEncoder records messages, but blocks until released so that tests
+// can fill the queue before anything is sent.
+type blockingEncoder struct {
+	recordingEncoder
+	release chan struct{}
+}
+
+func (b *blockingEncoder) Encode(m *Message) error {
+	<-b.release
+	return b.recordingEncoder.Encode(m)
+}
+
+func privmsg(target, text string) *Message {
+	return &Message{Command: "PRIVMSG", Params: []string{target}, Trailing: text}
+}
+
+func waitForLen(t *testing.T, r *recordingEncoder, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		l := len(r.messages)
+		r.mu.Unlock()
+
+		if l >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("timed out waiting for %d messages", n)
+}
+
+func TestQueuePriority(t *testing.T) {
+	b := &blockingEncoder{release: make(chan struct{})}
+	q := NewQueue(b, QueueOptions{})
+	defer q.Close()
+
+	// The first message is taken by the sender, which then blocks.
+	assert.NoError(t, q.Encode(privmsg("#a", "first")))
+	time.Sleep(10 * time.Millisecond)
+
+	assert.NoError(t, q.EncodePriority(privmsg("#a", "bulk"), PriorityBulk))
+	assert.NoError(t, q.Encode(privmsg("#a", "reply")))
+	assert.NoError(t, q.Encode(&Message{Command: "PONG", Trailing: "x"}))
+	assert.Equal(t, 3, q.Len())
+
+	close(b.release)
+	waitForLen(t, &b.recordingEncoder, 4)
+
+	var got []string
+	for _, m := range b.messages {
+		got = append(got, m.Command+" "+m.Trailing)
+	}
+
+	assert.Equal(t, []string{"PRIVMSG first", "PONG x", "PRIVMSG reply", "PRIVMSG bulk"}, got)
+}
+
+func TestQueueFairness(t *testing.T) {
+	b := &blockingEncoder{release: make(chan struct{})}
+	q := NewQueue(b, QueueOptions{})
+	defer q.Close()
+
+	assert.NoError(t, q.Encode(privmsg("#busy", "0")))
+	time.Sleep(10 * time.Millisecond)
+
+	for _, s := range []string{"1", "2", "3"} {
+		assert.NoError(t, q.Encode(privmsg("#busy", s)))
+	}
+	assert.NoError(t, q.Encode(privmsg("#quiet", "a")))
+	assert.NoError(t, q.Encode(privmsg("#other", "b")))
+
+	close(b.release)
+	waitForLen(t, &b.recordingEncoder, 6)
+
+	var got []string
+	for _, m := range b.messages {
+		got = append(got, m.Params[0]+" "+m.Trailing)
+	}
+
+	assert.Equal(t, []string{"#busy 0", "#busy 1", "#quiet a", "#other b", "#busy 2", "#busy 3"}, got)
+}
+
+func TestQueueDrop(t *testing.T) {
+	t.Run("newest", func(t *testing.T) {
+		b := &blockingEncoder{release: make(chan struct{})}
+
+		var dropped []string
+		q := NewQueue(b, QueueOptions{
+			MaxLen: 2,
+			OnDrop: func(m *Message) { dropped = append(dropped, m.Trailing) },
+		})
+		defer q.Close()
+
+		assert.NoError(t, q.Encode(privmsg("#a", "0")))
+		time.Sleep(10 * time.Millisecond)
+
+		assert.NoError(t, q.Encode(privmsg("#a", "1")))
+		assert.NoError(t, q.Encode(privmsg("#a", "2")))
+		assert.Equal(t, ErrQueueFull, q.Encode(privmsg("#a", "3")))
+
+		// Control messages are never dropped.
+		assert.NoError(t, q.Encode(&Message{Command: "PONG"}))
+
+		assert.Equal(t, []string{"3"}, dropped)
+		assert.Equal(t, 3, q.Len())
+		close(b.release)
+	})
+
+	t.Run("oldest", func(t *testing.T) {
+		b := &blockingEncoder{release: make(chan struct{})}
+
+		var dropped []string
+		q := NewQueue(b, QueueOptions{
+			MaxLen: 2,
+			Drop:   DropOldest,
+			OnDrop: func(m *Message) { dropped = append(dropped, m.Trailing) },
+		})
+		defer q.Close()
+
+		assert.NoError(t, q.Encode(privmsg("#a", "0")))
+		time.Sleep(10 * time.Millisecond)
+
+		assert.NoError(t, q.Encode(privmsg("#a", "1")))
+		assert.NoError(t, q.EncodePriority(privmsg("#b", "2"), PriorityBulk))
+		assert.NoError(t, q.Encode(privmsg("#c", "3")))
+		assert.NoError(t, q.Encode(privmsg("#c", "4")))
+
+		// Nothing is less important than bulk, so it is rejected.
+		assert.Equal(t, ErrQueueFull, q.EncodePriority(privmsg("#b", "5"), PriorityBulk))
+
+		assert.Equal(t, []string{"2", "1", "5"}, dropped)
+
+		close(b.release)
+		waitForLen(t, &b.recordingEncoder, 3)
+	})
+}
+
+func TestQueueError(t *testing.T) {
+	testErr := errors.New("test")
+	r := &recordingEncoder{err: testErr}
+	q := NewQueue(r, QueueOptions{})
+
+	assert.NoError(t, q.Encode(privmsg("#a", "0")))
+
+	deadline := time.Now().Add(time.Second)
+	for q.Encode(privmsg("#a", "1")) != testErr && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, testErr, q.Encode(privmsg("#a", "2")))
+	assert.NoError(t, q.Close())
+}
+
+func TestQueueClose(t *testing.T) {
+	q := NewQueue(&recordingEncoder{}, QueueOptions{})
+	assert.NoError(t, q.Close())
+	assert.Equal(t, ErrQueueClosed, q.Encode(privmsg("#a", "0")))
+}
+
+func TestQueueFlush(t *testing.T) {
+	b := &blockingEncoder{release: make(chan struct{})}
+	q := NewQueue(b, QueueOptions{})
+
+	assert.NoError(t, q.Flush(context.Background()))
+
+	assert.NoError(t, q.Encode(privmsg("#a", "0")))
+	assert.NoError(t, q.Encode(&Message{Command: "QUIT", Trailing: "bye"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, q.Flush(ctx))
+
+	close(b.release)
+	assert.NoError(t, q.Flush(context.Background()))
+	assert.ElementsMatch(t, []string{"PRIVMSG", "QUIT"}, b.commands())
+
+	assert.NoError(t, q.Close())
+	assert.Equal(t, ErrQueueClosed, q.Flush(context.Background()))
+}
+
+func TestQueueCopies(t *testing.T) {
+	b := &blockingEncoder{release: make(chan struct{})}
+	q := NewQueue(b, QueueOptions{})
+	defer q.Close()
+
+	// The message is reused, as when decoding into one in a loop.
+	m := &Message{Command: "PRIVMSG", Params: []string{"#a"}, Tags: map[string]string{"+t": "0"}}
+	for _, s := range []string{"0", "1"} {
+		m.Params[0] = "#" + s
+		m.Tags["+t"] = s
+		m.Trailing = s
+		assert.NoError(t, q.Encode(m))
+	}
+
+	close(b.release)
+	waitForLen(t, &b.recordingEncoder, 2)
+
+	for i, s := range []string{"0", "1"} {
+		assert.Equal(t, "#"+s, b.messages[i].Params[0])
+		assert.Equal(t, s, b.messages[i].Tags["+t"])
+		assert.Equal(t, s, b.messages[i].Trailing)
+	}
+}
+
+func TestQueueCaseMapping(t *testing.T) {
+	b := &blockingEncoder{release: make(chan struct{})}
+	q := NewQueue(b, QueueOptions{})
+	defer q.Close()
+
+	assert.NoError(t, q.Encode(privmsg("#a[", "0")))
+	time.Sleep(10 * time.Millisecond)
+
+	// "#A{" is the same channel as "#a[" under rfc1459, so shares its
+	// queue rather than being sent next.
+	assert.NoError(t, q.Encode(privmsg("#a[", "1")))
+	assert.NoError(t, q.Encode(privmsg("#A{", "2")))
+	assert.NoError(t, q.Encode(privmsg("#b", "3")))
+
+	close(b.release)
+	waitForLen(t, &b.recordingEncoder, 4)
+
+	var got []string
+	for _, m := range b.messages {
+		got = append(got, m.Trailing)
+	}
+	assert.Equal(t, []string{"0", "1", "3", "2"}, got)
+}
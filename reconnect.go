@@ -0,0 +1,341 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrDisconnected is returned by ReconnectConn.Encode when there is no
+// connection and the OutageFail policy is in use.
+var ErrDisconnected = errors.New("irc: disconnected")
+
+// OutagePolicy decides what ReconnectConn.Encode does while disconnected.
+type OutagePolicy int
+
+const (
+	// OutageFail makes Encode return ErrDisconnected.
+	OutageFail OutagePolicy = iota
+
+	// OutageQueue makes Encode queue a copy of the message, to be sent once
+	// a new connection has been established and OnConnect has returned. Once
+	// the queue is full, Encode returns ErrQueueFull. Messages which could
+	// not be sent stay queued for the next connection.
+	OutageQueue
+)
+
+// ReconnectOptions configures a ReconnectConn.
+type ReconnectOptions struct {
+	// Servers is the list of server addresses. Servers are tried in order,
+	// moving on to the next after a failed connection attempt.
+	Servers []string
+
+	// Dial connects to a server. If nil, BaseDialContext is used with a
+	// zero net.Dialer.
+	Dial func(ctx context.Context, addr string) (Conn, error)
+
+	// MinBackoff is the delay before the first reconnection attempt, which
+	// doubles after each consecutive failure. If zero, one second is used.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between attempts. If zero, five
+	// minutes is used.
+	MaxBackoff time.Duration
+
+	// OnConnect, if set, is called with each new connection before it is
+	// used, and is the place to register with the server. The connection may
+	// be used directly. If OnConnect returns an error, the connection is
+	// closed and the attempt is considered to have failed. The context is
+	// cancelled when the ReconnectConn is closed.
+	OnConnect func(ctx context.Context, c Conn, addr string) error
+
+	// OnDisconnect, if set, is called when a connection is lost, or a
+	// connection attempt fails, with the reason.
+	OnDisconnect func(addr string, err error)
+
+	// Outage is the policy for Encode calls made while disconnected.
+	Outage OutagePolicy
+
+	// QueueSize is the maximum number of messages queued by OutageQueue. If
+	// zero, 100 is used.
+	QueueSize int
+}
+
+// ReconnectConn is a Conn which transparently reconnects when its connection
+// is lost, with exponential backoff and server failover.
+//
+// Reconnection is driven by Decode, which blocks while reconnecting. Decode
+// only returns network errors once the ReconnectConn is closed, at which
+// point it returns io.EOF, so an irchandle.Client using a ReconnectConn runs
+// until the ReconnectConn is closed. Parse errors are returned as normal.
+type ReconnectConn struct {
+	opts   ReconnectOptions
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	decodeMu sync.Mutex
+	failures int
+	next     int
+
+	mu     sync.Mutex
+	conn   Conn
+	addr   string
+	queue  []*Message
+	closed bool
+}
+
+var _ Conn = (*ReconnectConn)(nil)
+
+// NewReconnectConn creates a new ReconnectConn. No connection is made until
+// the first call to Decode.
+func NewReconnectConn(opts ReconnectOptions) *ReconnectConn {
+	if opts.Dial == nil {
+		opts.Dial = func(ctx context.Context, addr string) (Conn, error) {
+			return BaseDialContext(ctx, nil, addr)
+		}
+	}
+
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &ReconnectConn{
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Addr returns the address of the current server, or the empty string if
+// not connected.
+func (r *ReconnectConn) Addr() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.addr
+}
+
+// Close closes the current connection and stops reconnecting.
+func (r *ReconnectConn) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	r.cancel()
+	r.queue = nil
+
+	if r.conn != nil {
+		return r.conn.Close()
+	}
+
+	return nil
+}
+
+// Encode encodes a message over the current connection. While disconnected,
+// the Outage policy applies. If encoding fails, the connection is closed so
+// that Decode will reconnect. After Close, Encode returns net.ErrClosed.
+func (r *ReconnectConn) Encode(m *Message) error {
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+		return net.ErrClosed
+	}
+
+	conn := r.conn
+	if conn == nil {
+		defer r.mu.Unlock()
+
+		if r.opts.Outage != OutageQueue {
+			return ErrDisconnected
+		}
+
+		if len(r.queue) >= r.opts.QueueSize {
+			return ErrQueueFull
+		}
+
+		r.queue = append(r.queue, m.clone())
+		return nil
+	}
+
+	r.mu.Unlock()
+
+	if err := conn.Encode(m); err != nil {
+		conn.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Decode decodes a message from the current connection, reconnecting as
+// needed.
+func (r *ReconnectConn) Decode(m *Message) error {
+	r.decodeMu.Lock()
+	defer r.decodeMu.Unlock()
+
+	for {
+		conn, err := r.connection()
+		if err != nil {
+			return err
+		}
+
+		err = conn.Decode(m)
+		if err == nil {
+			return nil
+		}
+
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return err
+		}
+
+		r.disconnected(conn, err)
+	}
+}
+
+// connection returns the current connection, connecting if there is none.
+// It must be called with decodeMu held.
+func (r *ReconnectConn) connection() (Conn, error) {
+	r.mu.Lock()
+	conn := r.conn
+	r.mu.Unlock()
+
+	if conn != nil {
+		return conn, nil
+	}
+
+	if len(r.opts.Servers) == 0 {
+		return nil, errors.New("irc: no servers to connect to")
+	}
+
+	for {
+		if r.ctx.Err() != nil {
+			return nil, io.EOF
+		}
+
+		if err := sleepContext(r.ctx, r.backoff()); err != nil {
+			return nil, io.EOF
+		}
+
+		addr := r.opts.Servers[r.next%len(r.opts.Servers)]
+
+		conn, err := r.connect(addr)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return nil, io.EOF
+			}
+
+			r.failures++
+			r.next++
+
+			if r.opts.OnDisconnect != nil {
+				r.opts.OnDisconnect(addr, err)
+			}
+			continue
+		}
+
+		r.failures = 0
+		return conn, nil
+	}
+}
+
+func (r *ReconnectConn) connect(addr string) (Conn, error) {
+	conn, err := r.opts.Dial(r.ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.opts.OnConnect != nil {
+		if err := r.opts.OnConnect(r.ctx, conn, addr); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		conn.Close()
+		return nil, net.ErrClosed
+	}
+
+	// Flush while holding the lock, so that queued messages are sent before
+	// any new ones.
+	for i, m := range r.queue {
+		if err := conn.Encode(m); err != nil {
+			r.queue = r.queue[i:]
+			conn.Close()
+			return nil, err
+		}
+	}
+	r.queue = nil
+
+	r.conn = conn
+	r.addr = addr
+
+	return conn, nil
+}
+
+func (r *ReconnectConn) disconnected(conn Conn, err error) {
+	conn.Close()
+
+	r.mu.Lock()
+	addr := r.addr
+	closed := r.closed
+	r.conn = nil
+	r.addr = ""
+	r.mu.Unlock()
+
+	if closed {
+		return
+	}
+
+	// Wait before reconnecting, even to a server which was working, so
+	// that a server which immediately drops connections is not hammered.
+	r.failures = 1
+
+	if r.opts.OnDisconnect != nil {
+		r.opts.OnDisconnect(addr, err)
+	}
+}
+
+// backoff returns the delay before the next connection attempt: zero for
+// the first attempt, then exponential with jitter between half and all of
+// the nominal delay.
+func (r *ReconnectConn) backoff() time.Duration {
+	if r.failures == 0 {
+		return 0
+	}
+
+	d := r.opts.MinBackoff
+	for i := 1; i < r.failures && d < r.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > r.opts.MaxBackoff {
+		d = r.opts.MaxBackoff
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
@@ -0,0 +1,225 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pipeServers is a fake set of servers, which hands the server side of each
+// connection to the test. Addresses in down fail to connect.
+type pipeServers struct {
+	mu    sync.Mutex
+	down  map[string]bool
+	dials []string
+	conns chan *BaseConn
+}
+
+func newPipeServers(down ...string) *pipeServers {
+	p := &pipeServers{
+		down:  make(map[string]bool),
+		conns: make(chan *BaseConn, 10),
+	}
+	for _, addr := range down {
+		p.down[addr] = true
+	}
+	return p
+}
+
+func (p *pipeServers) dial(ctx context.Context, addr string) (Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.dials = append(p.dials, addr)
+
+	if p.down[addr] {
+		return nil, errors.New("connection refused")
+	}
+
+	client, server := net.Pipe()
+	p.conns <- NewBaseConn(server)
+	return NewBaseConn(client), nil
+}
+
+func (p *pipeServers) dialed() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.dials...)
+}
+
+func TestReconnectConn(t *testing.T) {
+	servers := newPipeServers("a")
+
+	var mu sync.Mutex
+	var events []string
+
+	r := NewReconnectConn(ReconnectOptions{
+		Servers:    []string{"a", "b"},
+		Dial:       servers.dial,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		Outage:     OutageQueue,
+		OnConnect: func(ctx context.Context, c Conn, addr string) error {
+			mu.Lock()
+			events = append(events, "connect "+addr)
+			mu.Unlock()
+			return c.Encode(&Message{Command: "NICK", Params: []string{"bot"}})
+		},
+		OnDisconnect: func(addr string, err error) {
+			mu.Lock()
+			events = append(events, "disconnect "+addr)
+			mu.Unlock()
+		},
+	})
+
+	// Queued until connected, then sent after OnConnect.
+	assert.NoError(t, r.Encode(&Message{Command: "JOIN", Params: []string{"#a"}}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for _, want := range []string{"first", "second"} {
+			var m Message
+			assert.NoError(t, r.Decode(&m))
+			assert.Equal(t, want, m.Trailing)
+		}
+
+		var m Message
+		assert.Equal(t, io.EOF, r.Decode(&m))
+	}()
+
+	server := <-servers.conns
+
+	var m Message
+	assert.NoError(t, server.Decode(&m))
+	assert.Equal(t, "NICK", m.Command)
+	assert.NoError(t, server.Decode(&m))
+	assert.Equal(t, "JOIN", m.Command)
+
+	assert.Equal(t, "b", r.Addr())
+	assert.NoError(t, server.Encode(&Message{Command: "PRIVMSG", Trailing: "first"}))
+
+	// Drop the connection; the client reconnects to the same server.
+	assert.NoError(t, server.Close())
+
+	server = <-servers.conns
+	assert.NoError(t, server.Decode(&m))
+	assert.Equal(t, "NICK", m.Command)
+	assert.NoError(t, server.Encode(&Message{Command: "PRIVMSG", Trailing: "second"}))
+
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, r.Close())
+	<-done
+
+	assert.Equal(t, net.ErrClosed, r.Encode(&Message{Command: "PING"}))
+	assert.Equal(t, []string{"a", "b", "b"}, servers.dialed())
+	assert.Equal(t, []string{"disconnect a", "connect b", "disconnect b", "connect b"}, events)
+}
+
+func TestReconnectConnOutageQueue(t *testing.T) {
+	servers := newPipeServers()
+
+	r := NewReconnectConn(ReconnectOptions{
+		Servers:    []string{"a"},
+		Dial:       servers.dial,
+		MinBackoff: time.Millisecond,
+		Outage:     OutageQueue,
+	})
+
+	// Queued messages are copies, so the caller may reuse them.
+	m := &Message{Command: "PRIVMSG", Params: []string{"#a"}}
+	for _, text := range []string{"one", "two", "three"} {
+		m.Trailing = text
+		assert.NoError(t, r.Encode(m))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var m Message
+		r.Decode(&m) //nolint:errcheck
+	}()
+
+	// Drop the connection partway through flushing the queue; the rest is
+	// sent on the next one.
+	server := <-servers.conns
+	assert.NoError(t, server.Decode(m))
+	assert.Equal(t, "one", m.Trailing)
+	assert.NoError(t, server.Close())
+
+	server = <-servers.conns
+	for _, want := range []string{"two", "three"} {
+		assert.NoError(t, server.Decode(m))
+		assert.Equal(t, want, m.Trailing)
+	}
+
+	assert.NoError(t, r.Close())
+	<-done
+}
+
+func TestReconnectConnOutageFail(t *testing.T) {
+	r := NewReconnectConn(ReconnectOptions{Servers: []string{"a"}})
+	assert.Equal(t, ErrDisconnected, r.Encode(&Message{Command: "PING"}))
+	assert.NoError(t, r.Close())
+}
+
+func TestReconnectConnOnConnectError(t *testing.T) {
+	servers := newPipeServers()
+	testErr := errors.New("registration failed")
+
+	attempts := 0
+
+	r := NewReconnectConn(ReconnectOptions{
+		Servers:    []string{"a", "b", "c"},
+		Dial:       servers.dial,
+		MinBackoff: time.Millisecond,
+		OnConnect: func(ctx context.Context, c Conn, addr string) error {
+			attempts++
+			if attempts < 3 {
+				return testErr
+			}
+			return nil
+		},
+	})
+	defer r.Close()
+
+	go func() {
+		for s := range servers.conns {
+			s.Encode(&Message{Command: "PING"}) //nolint:errcheck
+		}
+	}()
+
+	var m Message
+	assert.NoError(t, r.Decode(&m))
+	assert.Equal(t, "c", r.Addr())
+	assert.Equal(t, []string{"a", "b", "c"}, servers.dialed())
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	r := NewReconnectConn(ReconnectOptions{
+		MinBackoff: time.Second,
+		MaxBackoff: 5 * time.Second,
+	})
+
+	assert.Zero(t, r.backoff())
+
+	for failures, nominal := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		r.failures = failures
+		d := r.backoff()
+		assert.True(t, d >= nominal/2 && d <= nominal, "failures=%d: %v", failures, d)
+	}
+}
@@ -0,0 +1,467 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrRegistrationTimeout is returned by Register when the context's
+	// deadline passes before registration completes.
+	ErrRegistrationTimeout = errors.New("irc: registration timed out")
+
+	// ErrNickRejected is returned (wrapped) by Register when the server
+	// rejects every nick given by the alternate nick strategy. The error
+	// also wraps the *NumericError of the last rejection, so errors.Is
+	// matches sentinels such as ErrNicknameInUse too.
+	ErrNickRejected = errors.New("irc: nick rejected")
+)
+
+// ServerError is returned when the server sends an ERROR message, which
+// it does before closing the connection.
+type ServerError struct {
+	Reason string
+}
+
+func (e *ServerError) Error() string {
+	return "irc: server error: " + e.Reason
+}
+
+// nickRejectedError is ErrNickRejected, wrapping the numeric which
+// rejected the last nick.
+type nickRejectedError struct {
+	err *NumericError
+}
+
+func (e *nickRejectedError) Error() string {
+	return ErrNickRejected.Error() + ": " + e.err.Target + ": " + e.err.Text
+}
+
+func (e *nickRejectedError) Is(target error) bool {
+	return target == ErrNickRejected
+}
+
+func (e *nickRejectedError) Unwrap() error {
+	return e.err
+}
+
+// RegisterOptions configures Register.
+type RegisterOptions struct {
+	// Nick is the desired nick.
+	Nick string
+
+	// User is the username. If empty, Nick is used.
+	User string
+
+	// RealName is the real name. If empty, Nick is used.
+	RealName string
+
+	// Password, if set, is sent with PASS.
+	Password string
+
+	// Caps, if set, is used to negotiate capabilities before registration
+	// completes. It is reset first, so may be reused across connections.
+	Caps *Caps
+
+	// SASL, if set, is used to authenticate during registration. If Caps is
+	// nil, one is created, and "sasl" is added to its Request if missing.
+	SASL SASLMechanism
+
+	// SASLOptional continues registration without authenticating if SASL
+	// is unavailable or fails, rather than returning an error.
+	SASLOptional bool
+
+	// Identity, if set, is updated with the client's identity as it is
+	// registered. It is reset first, so may be reused across connections.
+	Identity *Identity
+
+	// AltNick returns the nick to try after the server rejects a nick
+	// during registration, given the desired nick and the number of nicks
+	// which have been rejected so far. Returning the empty string gives up.
+	// If nil, DefaultAltNick is used.
+	AltNick func(nick string, attempt int) string
+}
+
+// DefaultAltNick appends underscores to the nick, giving up after three.
+func DefaultAltNick(nick string, attempt int) string {
+	if attempt > 3 {
+		return ""
+	}
+	return nick + strings.Repeat("_", attempt)
+}
+
+// Welcome is the result of a successful registration.
+type Welcome struct {
+	// Nick is the nick the server registered the client with.
+	Nick string
+
+	// Messages contains every message received from RPL_WELCOME to the end
+	// of the MOTD, inclusive.
+	Messages []*Message
+
+	// ISupport contains the tokens sent by the server in RPL_ISUPPORT.
+	ISupport *ISupport
+
+	// MOTD contains the lines of the message of the day.
+	MOTD []string
+
+	// Account is the account the client logged in to using SASL, if any.
+	Account string
+}
+
+// Register registers the connection with the server, sending PASS, NICK
+// and USER, then waiting until the server has sent RPL_WELCOME and the
+// message of the day. PINGs from the server are answered. It must be called
+// before anything else reads from the connection.
+//
+// If Caps is set, CAP LS 302 is sent first, and registration is held open
+// until the requested capabilities have been negotiated, at which point
+// CAP END is sent. Servers which do not support capabilities are handled.
+// If SASL is set, authentication happens before CAP END; if it fails, a
+// *SASLError is returned unless SASLOptional is set.
+//
+// If the server rejects the nick, the next nick from the AltNick strategy is
+// tried. If the server sends ERROR, a *ServerError is returned. If the
+// context's deadline passes, ErrRegistrationTimeout is returned. If the
+// context ends before registration completes, the connection is closed, as
+// it cannot be used further.
+func Register(ctx context.Context, conn Conn, opts RegisterOptions) (*Welcome, error) {
+	if opts.User == "" {
+		opts.User = opts.Nick
+	}
+
+	if opts.RealName == "" {
+		opts.RealName = opts.Nick
+	}
+
+	if opts.AltNick == nil {
+		opts.AltNick = DefaultAltNick
+	}
+
+	if opts.SASL != nil {
+		if opts.Caps == nil {
+			opts.Caps = &Caps{}
+		}
+
+		hasSASL := false
+		for _, name := range opts.Caps.Request {
+			if name == "sasl" {
+				hasSASL = true
+				break
+			}
+		}
+
+		// Copy rather than append, which could write into a backing array
+		// the caller shares.
+		if !hasSASL {
+			request := make([]string, len(opts.Caps.Request), len(opts.Caps.Request)+1)
+			copy(request, opts.Caps.Request)
+			opts.Caps.Request = append(request, "sasl")
+		}
+	}
+
+	r := &registration{
+		conn: conn,
+		opts: opts,
+		nick: opts.Nick,
+		welcome: &Welcome{
+			ISupport: &ISupport{},
+		},
+	}
+
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+
+	for {
+		m, err := decodeContext(ctx, conn)
+		if err != nil {
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				continue
+			}
+
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, ErrRegistrationTimeout
+			}
+			return nil, err
+		}
+
+		done, err := r.handle(m)
+		if err != nil {
+			return nil, err
+		}
+
+		if done {
+			return r.welcome, nil
+		}
+	}
+}
+
+type registration struct {
+	conn       Conn
+	opts       RegisterOptions
+	nick       string
+	attempts   int
+	registered bool
+	capsDone   bool
+	sasl       *saslState
+	welcome    *Welcome
+}
+
+func (r *registration) start() error {
+	if r.opts.Identity != nil {
+		r.opts.Identity.Reset()
+	}
+
+	if r.opts.Caps == nil {
+		r.capsDone = true
+	} else {
+		r.opts.Caps.Reset()
+
+		if err := r.conn.Encode(&Message{Command: "CAP", Params: []string{"LS", "302"}}); err != nil {
+			return err
+		}
+	}
+
+	if r.opts.Password != "" {
+		if err := r.conn.Encode(&Message{Command: "PASS", Params: []string{r.opts.Password}}); err != nil {
+			return err
+		}
+	}
+
+	if err := r.conn.Encode(&Message{Command: "NICK", Params: []string{r.nick}}); err != nil {
+		return err
+	}
+
+	return r.conn.Encode(&Message{
+		Command:  "USER",
+		Params:   []string{r.opts.User, "0", "*"},
+		Trailing: r.opts.RealName,
+	})
+}
+
+func (r *registration) handle(m *Message) (done bool, err error) {
+	if r.opts.Identity != nil {
+		r.opts.Identity.Handle(m)
+	}
+
+	switch m.Command {
+	case "PING":
+		return false, r.conn.Encode(&Message{
+			Command:        "PONG",
+			Params:         m.Params,
+			Trailing:       m.Trailing,
+			ForcedTrailing: m.ForcedTrailing,
+		})
+
+	case "ERROR":
+		return false, &ServerError{Reason: m.Trailing}
+
+	case "CAP":
+		if r.opts.Caps == nil {
+			return false, nil
+		}
+
+		if _, err := r.opts.Caps.Handle(r.conn, m); err != nil {
+			return false, err
+		}
+
+		if !r.capsDone && r.sasl == nil && r.opts.Caps.settled() {
+			return false, r.capsSettled()
+		}
+
+		return false, nil
+
+	case "AUTHENTICATE":
+		if r.sasl == nil {
+			return false, nil
+		}
+		return false, r.authenticate(m)
+
+	case RPL_LOGGEDIN:
+		if len(m.Params) > 2 {
+			r.welcome.Account = m.Params[2]
+		}
+
+	case RPL_SASLMECHS:
+		if r.sasl != nil && len(m.Params) > 1 {
+			r.sasl.mechanisms = strings.Split(m.Params[1], ",")
+		}
+		return false, nil
+
+	case RPL_SASLSUCCESS, ERR_SASLALREADY:
+		if r.sasl != nil && !r.capsDone {
+			return false, r.endCaps()
+		}
+		return false, nil
+
+	case ERR_NICKLOCKED, ERR_SASLFAIL, ERR_SASLTOOLONG, ERR_SASLABORTED:
+		if r.sasl == nil || r.capsDone {
+			return false, nil
+		}
+
+		if r.opts.SASLOptional {
+			return false, r.endCaps()
+		}
+
+		return false, &SASLError{
+			Code:       m.Command,
+			Message:    m.Trailing,
+			Mechanisms: r.sasl.mechanisms,
+		}
+
+	case ERR_UNKNOWNCOMMAND:
+		// The server does not support capabilities, and will register
+		// without waiting for CAP END.
+		if !r.capsDone && len(m.Params) > 1 && strings.EqualFold(m.Params[1], "CAP") {
+			r.capsDone = true
+			r.opts.Caps.finish()
+		}
+		return false, nil
+	}
+
+	if !r.registered {
+		switch m.Command {
+		case ERR_NICKNAMEINUSE, ERR_ERRONEUSNICKNAME, ERR_NICKCOLLISION, ERR_UNAVAILRESOURCE:
+			return false, r.retryNick(m)
+
+		case RPL_WELCOME:
+			r.registered = true
+
+			// A server which ignored CAP LS has registered anyway.
+			if !r.capsDone {
+				r.capsDone = true
+				r.opts.Caps.finish()
+			}
+
+			if len(m.Params) > 0 {
+				r.nick = m.Params[0]
+			}
+			r.welcome.Nick = r.nick
+
+		default:
+			return false, nil
+		}
+	}
+
+	r.welcome.Messages = append(r.welcome.Messages, m)
+
+	switch m.Command {
+	case RPL_ISUPPORT:
+		r.welcome.ISupport.Update(m)
+
+	case RPL_MOTD:
+		r.welcome.MOTD = append(r.welcome.MOTD, strings.TrimPrefix(m.Trailing, "- "))
+
+	case RPL_ENDOFMOTD, ERR_NOMOTD:
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// capsSettled is called once capability negotiation has settled, and
+// starts SASL if it is wanted, or ends negotiation.
+func (r *registration) capsSettled() error {
+	if r.opts.SASL == nil {
+		return r.endCaps()
+	}
+
+	name := r.opts.SASL.Name()
+
+	mechs, ok := r.opts.Caps.Available("sasl")
+	if ok && mechs != "" {
+		ok = false
+		for _, mech := range strings.Split(mechs, ",") {
+			if mech == name {
+				ok = true
+				break
+			}
+		}
+	}
+
+	if !ok || !r.opts.Caps.Enabled("sasl") {
+		if r.opts.SASLOptional {
+			return r.endCaps()
+		}
+		return fmt.Errorf("%w: %s", ErrSASLUnavailable, name)
+	}
+
+	r.sasl = &saslState{mech: r.opts.SASL}
+	return r.conn.Encode(&Message{Command: "AUTHENTICATE", Params: []string{name}})
+}
+
+func (r *registration) authenticate(m *Message) error {
+	msgs, err := r.sasl.handle(m)
+	if err != nil {
+		// Abort, after which the server replies with ERR_SASLABORTED.
+		if abortErr := r.conn.Encode(&Message{Command: "AUTHENTICATE", Params: []string{"*"}}); abortErr != nil {
+			return abortErr
+		}
+
+		if r.opts.SASLOptional {
+			return nil
+		}
+		return err
+	}
+
+	for _, msg := range msgs {
+		if err := r.conn.Encode(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *registration) endCaps() error {
+	r.capsDone = true
+	r.opts.Caps.finish()
+	return r.conn.Encode(&Message{Command: "CAP", Params: []string{"END"}})
+}
+
+func (r *registration) retryNick(m *Message) error {
+	r.attempts++
+
+	nick := r.opts.AltNick(r.opts.Nick, r.attempts)
+	if nick == "" {
+		err, _ := ParseNumericError(m)
+		return &nickRejectedError{err}
+	}
+
+	r.nick = nick
+	return r.conn.Encode(&Message{Command: "NICK", Params: []string{nick}})
+}
+
+// decodeContext decodes a message from conn, closing conn if the context
+// ends first.
+func decodeContext(ctx context.Context, conn Conn) (*Message, error) {
+	if err := ctx.Err(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	type result struct {
+		m   *Message
+		err error
+	}
+
+	ch := make(chan result, 1)
+
+	go func() {
+		m := &Message{}
+		err := conn.Decode(m)
+		ch <- result{m, err}
+	}()
+
+	select {
+	case res := <-ch:
+		return res.m, res.err
+	case <-ctx.Done():
+		conn.Close()
+		return nil, ctx.Err()
+	}
+}
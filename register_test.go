@@ -0,0 +1,151 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runScript plays the server side of a conversation. Lines starting with
+// "C: " must be received from the client, and lines starting with "S: " are
+// sent to it. The returned channel is closed once the script is finished.
+func runScript(t *testing.T, conn *BaseConn, script string) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for _, line := range strings.Split(strings.TrimSpace(script), "\n") {
+			line = strings.TrimSpace(line)
+
+			switch {
+			case strings.HasPrefix(line, "C: "):
+				var m Message
+				if err := conn.Decode(&m); err != nil {
+					t.Errorf("expected %q, got error %v", line[3:], err)
+					return
+				}
+				assert.Equal(t, line[3:], m.Raw)
+
+			case strings.HasPrefix(line, "S: "):
+				if _, err := conn.conn.Write([]byte(line[3:] + "\r\n")); err != nil {
+					t.Errorf("error sending %q: %v", line[3:], err)
+					return
+				}
+
+			default:
+				t.Errorf("bad script line %q", line)
+				return
+			}
+		}
+	}()
+
+	return done
+}
+
+func scriptConn(t *testing.T, script string) (*BaseConn, <-chan struct{}) {
+	client, server := net.Pipe()
+	return NewBaseConn(client), runScript(t, NewBaseConn(server), script)
+}
+
+func TestRegister(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: PASS hunter2
+		C: NICK jake
+		C: USER jakeb 0 * :Jake Bailey
+		S: :irc.example.org NOTICE * :*** Looking up your hostname
+		S: PING :12345
+		C: PONG :12345
+		S: :irc.example.org 433 * jake :Nickname is already in use
+		C: NICK jake_
+		S: :irc.example.org 001 jake_ :Welcome to the network
+		S: :irc.example.org 002 jake_ :Your host is irc.example.org
+		S: :irc.example.org 005 jake_ CHANTYPES=# CHANLIMIT=#:120 NETWORK=Example\x20Net :are supported by this server
+		S: :irc.example.org 375 jake_ :- irc.example.org Message of the Day -
+		S: :irc.example.org 372 jake_ :- Hello
+		S: :irc.example.org 372 jake_ :- World
+		S: :irc.example.org 376 jake_ :End of /MOTD command.
+	`)
+
+	w, err := Register(context.Background(), conn, RegisterOptions{
+		Nick:     "jake",
+		User:     "jakeb",
+		RealName: "Jake Bailey",
+		Password: "hunter2",
+	})
+	assert.NoError(t, err)
+	<-done
+
+	assert.Equal(t, "jake_", w.Nick)
+	assert.Equal(t, []string{"Hello", "World"}, w.MOTD)
+	assert.Len(t, w.Messages, 7)
+
+	v, ok := w.ISupport.Get("CHANLIMIT")
+	assert.True(t, ok)
+	assert.Equal(t, "#:120", v)
+
+	v, _ = w.ISupport.Get("NETWORK")
+	assert.Equal(t, "Example Net", v)
+}
+
+func TestRegisterNickRejected(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org 432 * jake :Erroneous nickname
+		C: NICK jake2
+		S: :irc.example.org 433 * jake2 :Nickname is already in use
+	`)
+
+	_, err := Register(context.Background(), conn, RegisterOptions{
+		Nick: "jake",
+		AltNick: func(nick string, attempt int) string {
+			if attempt > 1 {
+				return ""
+			}
+			return "jake2"
+		},
+	})
+	<-done
+
+	assert.True(t, errors.Is(err, ErrNickRejected))
+	assert.EqualError(t, err, "irc: nick rejected: jake2: Nickname is already in use")
+	assert.True(t, errors.Is(err, ErrNicknameInUse))
+
+	var numeric *NumericError
+	assert.True(t, errors.As(err, &numeric))
+	assert.Equal(t, ERR_NICKNAMEINUSE, numeric.Code)
+}
+
+func TestRegisterServerError(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: ERROR :Closing Link: jake (K-Lined)
+	`)
+
+	_, err := Register(context.Background(), conn, RegisterOptions{Nick: "jake"})
+	<-done
+
+	assert.Equal(t, &ServerError{Reason: "Closing Link: jake (K-Lined)"}, err)
+}
+
+func TestRegisterTimeout(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: NICK jake
+		C: USER jake 0 * :jake
+	`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := Register(ctx, conn, RegisterOptions{Nick: "jake"})
+	<-done
+
+	assert.Equal(t, ErrRegistrationTimeout, err)
+}
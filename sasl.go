@@ -0,0 +1,347 @@
+package irc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// saslChunkLen is the maximum length of the base64 data in a single
+// AUTHENTICATE message.
+const saslChunkLen = 400
+
+var (
+	// ErrSASLUnavailable is returned (wrapped) by Register when the server
+	// does not support SASL, or the chosen mechanism.
+	ErrSASLUnavailable = errors.New("irc: sasl unavailable")
+
+	errSCRAMNonce     = errors.New("irc: scram: server nonce does not extend client nonce")
+	errSCRAMSignature = errors.New("irc: scram: invalid server signature")
+)
+
+// SASLError is a failed SASL authentication, from one of the numerics
+// ERR_NICKLOCKED, ERR_SASLFAIL, ERR_SASLTOOLONG or ERR_SASLABORTED.
+// ERR_SASLALREADY is not a failure, as the client is already logged in.
+type SASLError struct {
+	// Code is the numeric sent by the server.
+	Code string
+
+	// Message is the server's description of the failure.
+	Message string
+
+	// Mechanisms lists the mechanisms supported by the server, if it sent
+	// RPL_SASLMECHS.
+	Mechanisms []string
+}
+
+func (e *SASLError) Error() string {
+	return "irc: sasl authentication failed (" + e.Code + "): " + e.Message
+}
+
+// Unwrap returns the numeric as a *NumericError, so that errors.As finds it
+// as it does for other numeric replies.
+func (e *SASLError) Unwrap() error {
+	return &NumericError{Code: e.Code, Name: numericErrors[e.Code].name, Text: e.Message}
+}
+
+// SASLMechanism is a client implementation of a SASL mechanism. Start is
+// called at the beginning of each exchange, so a mechanism may be reused for
+// later connections, but not concurrently.
+type SASLMechanism interface {
+	// Name returns the name of the mechanism, for example "PLAIN".
+	Name() string
+
+	// Start begins an exchange, returning the initial response.
+	Start() ([]byte, error)
+
+	// Next returns the response to a challenge from the server.
+	Next(challenge []byte) ([]byte, error)
+}
+
+// SASLPlain implements the PLAIN mechanism (RFC 4616).
+type SASLPlain struct {
+	// Identity is the authorization identity, which is usually empty.
+	Identity string
+	Username string
+	Password string
+}
+
+var _ SASLMechanism = (*SASLPlain)(nil)
+
+// Name returns "PLAIN".
+func (*SASLPlain) Name() string { return "PLAIN" }
+
+// Start returns the credentials.
+func (s *SASLPlain) Start() ([]byte, error) {
+	return []byte(s.Identity + "\x00" + s.Username + "\x00" + s.Password), nil
+}
+
+// Next returns an error, as PLAIN has no challenges.
+func (s *SASLPlain) Next(challenge []byte) ([]byte, error) {
+	return nil, errors.New("irc: sasl plain: unexpected challenge")
+}
+
+// SASLExternal implements the EXTERNAL mechanism (RFC 4422), which
+// authenticates using credentials from outside of IRC. On IRC, this is
+// usually a TLS client certificate, set in the tls.Config used to connect
+// (for example, TLSDialer.Config.Certificates).
+type SASLExternal struct {
+	// Identity is the authorization identity, which is usually empty.
+	Identity string
+}
+
+var _ SASLMechanism = (*SASLExternal)(nil)
+
+// Name returns "EXTERNAL".
+func (*SASLExternal) Name() string { return "EXTERNAL" }
+
+// Start returns the identity.
+func (s *SASLExternal) Start() ([]byte, error) {
+	return []byte(s.Identity), nil
+}
+
+// Next returns an error, as EXTERNAL has no challenges.
+func (s *SASLExternal) Next(challenge []byte) ([]byte, error) {
+	return nil, errors.New("irc: sasl external: unexpected challenge")
+}
+
+// SASLScramSHA256 implements the SCRAM-SHA-256 mechanism (RFC 7677), which
+// does not send the password to the server, and verifies that the server
+// knows it. The username and password are not normalized with SASLprep, so
+// should be ASCII.
+type SASLScramSHA256 struct {
+	Username string
+	Password string
+
+	// nonce overrides the client nonce in tests.
+	nonce string
+
+	step            int
+	clientFirstBare string
+	authMessage     string
+	saltedPassword  []byte
+}
+
+var _ SASLMechanism = (*SASLScramSHA256)(nil)
+
+// Name returns "SCRAM-SHA-256".
+func (*SASLScramSHA256) Name() string { return "SCRAM-SHA-256" }
+
+// Start returns the client-first-message.
+func (s *SASLScramSHA256) Start() ([]byte, error) {
+	nonce := s.nonce
+	if nonce == "" {
+		b := make([]byte, 18)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		nonce = base64.StdEncoding.EncodeToString(b)
+	}
+
+	username := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(s.Username)
+
+	s.step = 1
+	s.clientFirstBare = "n=" + username + ",r=" + nonce
+	s.authMessage = ""
+	s.saltedPassword = nil
+
+	return []byte("n,," + s.clientFirstBare), nil
+}
+
+// Next returns the client-final-message in response to the server-first-
+// message, then verifies the server-final-message.
+func (s *SASLScramSHA256) Next(challenge []byte) ([]byte, error) {
+	switch s.step {
+	case 1:
+		s.step++
+		return s.clientFinal(string(challenge))
+	case 2:
+		s.step++
+		return nil, s.verify(string(challenge))
+	default:
+		return nil, errors.New("irc: scram: unexpected challenge")
+	}
+}
+
+func (s *SASLScramSHA256) clientFinal(serverFirst string) ([]byte, error) {
+	attrs := scramAttributes(serverFirst)
+
+	if msg, ok := attrs["e"]; ok {
+		return nil, errors.New("irc: scram: " + msg)
+	}
+
+	clientNonce := s.clientFirstBare[strings.Index(s.clientFirstBare, ",r=")+3:]
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, clientNonce) || len(nonce) == len(clientNonce) {
+		return nil, errSCRAMNonce
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, errors.New("irc: scram: invalid salt")
+	}
+
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return nil, errors.New("irc: scram: invalid iteration count")
+	}
+
+	s.saltedPassword = pbkdf2SHA256([]byte(s.Password), salt, iterations)
+
+	clientFinalWithoutProof := "c=biws,r=" + nonce
+	s.authMessage = s.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof
+
+	clientKey := hmacSHA256(s.saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	clientSignature := hmacSHA256(storedKey[:], []byte(s.authMessage))
+
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	return []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (s *SASLScramSHA256) verify(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+
+	if msg, ok := attrs["e"]; ok {
+		return errors.New("irc: scram: " + msg)
+	}
+
+	got, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil {
+		return errSCRAMSignature
+	}
+
+	serverKey := hmacSHA256(s.saltedPassword, []byte("Server Key"))
+	want := hmacSHA256(serverKey, []byte(s.authMessage))
+
+	if !hmac.Equal(got, want) {
+		return errSCRAMSignature
+	}
+
+	return nil
+}
+
+func scramAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+
+	for _, attr := range strings.Split(msg, ",") {
+		if len(attr) >= 2 && attr[1] == '=' {
+			attrs[attr[:1]] = attr[2:]
+		}
+	}
+
+	return attrs
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA-256, producing a
+// single block, which is all SCRAM needs.
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	h := hmac.New(sha256.New, password)
+	h.Write(salt)
+	h.Write([]byte{0, 0, 0, 1})
+	u := h.Sum(nil)
+
+	out := make([]byte, len(u))
+	copy(out, u)
+
+	for i := 1; i < iterations; i++ {
+		h.Reset()
+		h.Write(u)
+		u = h.Sum(u[:0])
+
+		for j := range out {
+			out[j] ^= u[j]
+		}
+	}
+
+	return out
+}
+
+// saslEncode encodes a SASL response as AUTHENTICATE messages, splitting it
+// into chunks and terminating it with "+" when the last chunk is full.
+func saslEncode(response []byte) []*Message {
+	if len(response) == 0 {
+		return []*Message{{Command: "AUTHENTICATE", Params: []string{"+"}}}
+	}
+
+	data := base64.StdEncoding.EncodeToString(response)
+
+	var msgs []*Message
+
+	for len(data) > 0 {
+		n := len(data)
+		if n > saslChunkLen {
+			n = saslChunkLen
+		}
+
+		msgs = append(msgs, &Message{Command: "AUTHENTICATE", Params: []string{data[:n]}})
+		data = data[n:]
+
+		if n == saslChunkLen && len(data) == 0 {
+			msgs = append(msgs, &Message{Command: "AUTHENTICATE", Params: []string{"+"}})
+		}
+	}
+
+	return msgs
+}
+
+// saslState tracks a SASL exchange during registration.
+type saslState struct {
+	mech       SASLMechanism
+	started    bool
+	challenge  strings.Builder
+	mechanisms []string
+}
+
+// handle processes an AUTHENTICATE message, returning the responses to
+// send, which are nil while a chunked challenge is incomplete.
+func (s *saslState) handle(m *Message) ([]*Message, error) {
+	var data string
+	if len(m.Params) > 0 {
+		data = m.Params[0]
+	} else {
+		data = m.Trailing
+	}
+
+	if data != "+" {
+		s.challenge.WriteString(data)
+		if len(data) == saslChunkLen {
+			// More to come.
+			return nil, nil
+		}
+	}
+
+	challenge, err := base64.StdEncoding.DecodeString(s.challenge.String())
+	s.challenge.Reset()
+	if err != nil {
+		return nil, errors.New("irc: sasl: invalid challenge encoding")
+	}
+
+	var response []byte
+	if !s.started {
+		s.started = true
+		response, err = s.mech.Start()
+	} else {
+		response, err = s.mech.Next(challenge)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return saslEncode(response), nil
+}
@@ -0,0 +1,242 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterSASLPlain(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org CAP * LS :sasl=PLAIN,EXTERNAL
+		C: CAP REQ :sasl
+		S: :irc.example.org CAP * ACK :sasl
+		C: AUTHENTICATE PLAIN
+		S: AUTHENTICATE +
+		C: AUTHENTICATE AGpha2UAaHVudGVyMg==
+		S: :irc.example.org 900 jake jake!jake@example.org jbailey :You are now logged in as jbailey
+		S: :irc.example.org 903 jake :SASL authentication successful
+		C: CAP END
+		S: :irc.example.org 001 jake :Welcome
+		S: :irc.example.org 422 jake :MOTD File is missing
+	`)
+
+	w, err := Register(context.Background(), conn, RegisterOptions{
+		Nick: "jake",
+		SASL: &SASLPlain{Username: "jake", Password: "hunter2"},
+	})
+	assert.NoError(t, err)
+	<-done
+
+	assert.Equal(t, "jbailey", w.Account)
+}
+
+func TestRegisterSASLExternal(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org CAP * LS :sasl
+		C: CAP REQ :sasl
+		S: :irc.example.org CAP * ACK :sasl
+		C: AUTHENTICATE EXTERNAL
+		S: AUTHENTICATE +
+		C: AUTHENTICATE +
+		S: :irc.example.org 903 jake :SASL authentication successful
+		C: CAP END
+		S: :irc.example.org 001 jake :Welcome
+		S: :irc.example.org 422 jake :MOTD File is missing
+	`)
+
+	// "sasl" is added to the request without writing into the caller's
+	// backing array.
+	backing := make([]string, 0, 1)
+	caps := &Caps{Request: backing}
+
+	_, err := Register(context.Background(), conn, RegisterOptions{
+		Nick: "jake",
+		SASL: &SASLExternal{},
+		Caps: caps,
+	})
+	assert.NoError(t, err)
+	<-done
+
+	assert.Equal(t, []string{"sasl"}, caps.Request)
+	assert.Equal(t, "", backing[:1][0])
+}
+
+func TestRegisterSASLScram(t *testing.T) {
+	// The exchange from RFC 7677, section 3.
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org CAP * LS :sasl=SCRAM-SHA-256
+		C: CAP REQ :sasl
+		S: :irc.example.org CAP * ACK :sasl
+		C: AUTHENTICATE SCRAM-SHA-256
+		S: AUTHENTICATE +
+		C: AUTHENTICATE biwsbj11c2VyLHI9ck9wck5HZndFYmVSV2diTkVrcU8=
+		S: AUTHENTICATE cj1yT3ByTkdmd0ViZVJXZ2JORWtxTyVodllEcFdVYTJSYVRDQWZ1eEZJbGopaE5sRiRrMCxzPVcyMlphSjBTTlk3c29Fc1VFamI2Z1E9PSxpPTQwOTY=
+		C: AUTHENTICATE Yz1iaXdzLHI9ck9wck5HZndFYmVSV2diTkVrcU8laHZZRHBXVWEyUmFUQ0FmdXhGSWxqKWhObEYkazAscD1kSHpiWmFwV0lrNGpVaE4rVXRlOXl0YWc5empmTUhnc3FtbWl6N0FuZFZRPQ==
+		S: AUTHENTICATE dj02cnJpVFJCaTIzV3BSUi93dHVwK21NaFVaVW4vZEI1bkxUSlJzamw5NUc0PQ==
+		C: AUTHENTICATE +
+		S: :irc.example.org 903 jake :SASL authentication successful
+		C: CAP END
+		S: :irc.example.org 001 jake :Welcome
+		S: :irc.example.org 422 jake :MOTD File is missing
+	`)
+
+	_, err := Register(context.Background(), conn, RegisterOptions{
+		Nick: "jake",
+		SASL: &SASLScramSHA256{Username: "user", Password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO"},
+	})
+	assert.NoError(t, err)
+	<-done
+}
+
+func TestScramBadSignature(t *testing.T) {
+	s := &SASLScramSHA256{Username: "user", Password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO"}
+
+	_, err := s.Start()
+	assert.NoError(t, err)
+
+	_, err = s.Next([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	assert.NoError(t, err)
+
+	_, err = s.Next([]byte("v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="))
+	assert.Equal(t, errSCRAMSignature, err)
+
+	_, _ = s.Start()
+	_, err = s.Next([]byte("r=somethingelse,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	assert.Equal(t, errSCRAMNonce, err)
+}
+
+func TestRegisterSASLFail(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org CAP * LS :sasl
+		C: CAP REQ :sasl
+		S: :irc.example.org CAP * ACK :sasl
+		C: AUTHENTICATE PLAIN
+		S: AUTHENTICATE +
+		C: AUTHENTICATE AGpha2UAaHVudGVyMg==
+		S: :irc.example.org 908 jake EXTERNAL,SCRAM-SHA-256 :are available SASL mechanisms
+		S: :irc.example.org 904 jake :SASL authentication failed
+	`)
+
+	_, err := Register(context.Background(), conn, RegisterOptions{
+		Nick: "jake",
+		SASL: &SASLPlain{Username: "jake", Password: "hunter2"},
+	})
+	<-done
+
+	var saslErr *SASLError
+	assert.True(t, errors.As(err, &saslErr))
+	assert.Equal(t, &SASLError{
+		Code:       ERR_SASLFAIL,
+		Message:    "SASL authentication failed",
+		Mechanisms: []string{"EXTERNAL", "SCRAM-SHA-256"},
+	}, saslErr)
+
+	var numeric *NumericError
+	assert.True(t, errors.As(err, &numeric))
+	assert.Equal(t, ERR_SASLFAIL, numeric.Code)
+}
+
+func TestRegisterSASLOptional(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org CAP * LS :sasl=EXTERNAL server-time
+		C: CAP REQ :server-time sasl
+		S: :irc.example.org CAP * ACK :server-time sasl
+		C: CAP END
+		S: :irc.example.org 001 jake :Welcome
+		S: :irc.example.org 422 jake :MOTD File is missing
+	`)
+
+	_, err := Register(context.Background(), conn, RegisterOptions{
+		Nick:         "jake",
+		Caps:         &Caps{Request: []string{"server-time"}},
+		SASL:         &SASLPlain{Username: "jake", Password: "hunter2"},
+		SASLOptional: true,
+	})
+	assert.NoError(t, err)
+	<-done
+}
+
+func TestRegisterSASLUnavailable(t *testing.T) {
+	conn, done := scriptConn(t, `
+		C: CAP LS 302
+		C: NICK jake
+		C: USER jake 0 * :jake
+		S: :irc.example.org CAP * LS :server-time
+	`)
+
+	_, err := Register(context.Background(), conn, RegisterOptions{
+		Nick: "jake",
+		SASL: &SASLPlain{Username: "jake", Password: "hunter2"},
+	})
+	<-done
+
+	assert.True(t, errors.Is(err, ErrSASLUnavailable))
+}
+
+func TestSASLChunks(t *testing.T) {
+	params := func(msgs []*Message) []string {
+		var out []string
+		for _, m := range msgs {
+			out = append(out, m.Params[0])
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"+"}, params(saslEncode(nil)))
+
+	// 300 bytes encode to exactly 400 base64 characters.
+	msgs := saslEncode([]byte(strings.Repeat("a", 300)))
+	assert.Len(t, msgs, 2)
+	assert.Len(t, msgs[0].Params[0], 400)
+	assert.Equal(t, "+", msgs[1].Params[0])
+
+	msgs = saslEncode([]byte(strings.Repeat("a", 400)))
+	assert.Len(t, msgs, 2)
+	assert.Len(t, msgs[0].Params[0], 400)
+	assert.Len(t, msgs[1].Params[0], 136)
+
+	// A chunked challenge is reassembled before being passed on.
+	mech := &recordingMechanism{}
+	s := &saslState{mech: mech, started: true}
+	challenge := strings.Repeat("b", 300)
+
+	for _, m := range saslEncode([]byte(challenge)) {
+		msgs, err := s.handle(m)
+		assert.NoError(t, err)
+		if m.Params[0] != "+" {
+			assert.Nil(t, msgs)
+		}
+	}
+
+	assert.Equal(t, []string{challenge}, mech.challenges)
+}
+
+type recordingMechanism struct {
+	challenges []string
+}
+
+func (*recordingMechanism) Name() string           { return "TEST" }
+func (*recordingMechanism) Start() ([]byte, error) { return nil, nil }
+func (r *recordingMechanism) Next(c []byte) ([]byte, error) {
+	r.challenges = append(r.challenges, string(c))
+	return nil, nil
+}
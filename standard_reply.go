@@ -0,0 +1,72 @@
+package irc
+
+// The types of standard reply.
+const (
+	ReplyFail = "FAIL"
+	ReplyWarn = "WARN"
+	ReplyNote = "NOTE"
+)
+
+// StandardReply is an IRCv3 standard reply, sent as:
+//
+//	FAIL <command> <code> [<context>...] :<description>
+//
+// with FAIL, WARN or NOTE as the command. The request helpers in irchandle
+// return FAIL replies as errors, so they can be inspected with errors.As.
+type StandardReply struct {
+	// Type is one of ReplyFail, ReplyWarn or ReplyNote.
+	Type string
+
+	// Command is the command the reply relates to, or "*" if it does not
+	// relate to a particular command.
+	Command string
+
+	// Code is a machine-readable code, for example "NEED_MORE_PARAMS".
+	Code string
+
+	// Context holds any parameters between the code and the description.
+	Context []string
+
+	// Description is the human-readable description of the reply.
+	Description string
+}
+
+// ParseStandardReply parses a FAIL, WARN or NOTE message, returning false if
+// m is not a well-formed standard reply.
+func ParseStandardReply(m *Message) (r *StandardReply, ok bool) {
+	switch m.Command {
+	case ReplyFail, ReplyWarn, ReplyNote:
+	default:
+		return nil, false
+	}
+
+	params := m.AllParams()
+	if len(params) < 3 {
+		return nil, false
+	}
+
+	r = &StandardReply{
+		Type:        m.Command,
+		Command:     params[0],
+		Code:        params[1],
+		Description: params[len(params)-1],
+	}
+
+	if len(params) > 3 {
+		r.Context = params[2 : len(params)-1]
+	}
+
+	return r, true
+}
+
+// Fail returns true if the reply is a FAIL.
+func (r *StandardReply) Fail() bool {
+	return r.Type == ReplyFail
+}
+
+// Error implements error, so that a FAIL reply can be returned as one. WARN
+// and NOTE replies do not report failures, so should not be used as errors,
+// though Error still describes them.
+func (r *StandardReply) Error() string {
+	return "irc: " + r.Command + " " + r.Code + ": " + r.Description
+}
@@ -0,0 +1,48 @@
+package irc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStandardReply(t *testing.T) {
+	tests := []struct {
+		line string
+		want *StandardReply
+	}{
+		{
+			line: "FAIL CHATHISTORY INVALID_TARGET LATEST #chan :Messages could not be retrieved",
+			want: &StandardReply{Type: ReplyFail, Command: "CHATHISTORY", Code: "INVALID_TARGET", Context: []string{"LATEST", "#chan"}, Description: "Messages could not be retrieved"},
+		},
+		{
+			line: ":irc.example.org WARN REHASH CERTS_EXPIRED :Certificate has expired",
+			want: &StandardReply{Type: ReplyWarn, Command: "REHASH", Code: "CERTS_EXPIRED", Description: "Certificate has expired"},
+		},
+		{
+			line: "NOTE * OPER_MESSAGE :The server is restarting",
+			want: &StandardReply{Type: ReplyNote, Command: "*", Code: "OPER_MESSAGE", Description: "The server is restarting"},
+		},
+		{line: "FAIL JOIN :Missing code"},
+		{line: "PRIVMSG #chan :FAIL"},
+	}
+
+	for _, test := range tests {
+		m, err := ParseMessage(test.line)
+		assert.NoError(t, err)
+
+		r, ok := ParseStandardReply(m)
+		assert.Equal(t, test.want != nil, ok, test.line)
+		assert.Equal(t, test.want, r, test.line)
+	}
+}
+
+func TestStandardReplyError(t *testing.T) {
+	var err error = &StandardReply{Type: ReplyFail, Command: "JOIN", Code: "CHANNEL_FULL", Description: "Channel is full"}
+
+	var r *StandardReply
+	assert.True(t, errors.As(err, &r))
+	assert.True(t, r.Fail())
+	assert.Equal(t, "irc: JOIN CHANNEL_FULL: Channel is full", err.Error())
+}
@@ -0,0 +1,851 @@
+package irc
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+// Kinds of changes reported by State.
+const (
+	// ChangeJoin is reported when Nick joins Channel.
+	ChangeJoin ChangeKind = iota
+
+	// ChangePart is reported when Nick leaves Channel, by parting or being
+	// kicked.
+	ChangePart
+
+	// ChangeQuit is reported when Nick quits.
+	ChangeQuit
+
+	// ChangeNick is reported when Nick changes their nick to NewNick.
+	ChangeNick
+
+	// ChangeTopic is reported when the topic of Channel changes, or is
+	// first received.
+	ChangeTopic
+
+	// ChangeModes is reported when the modes or lists of Channel change,
+	// including the prefix modes of its members.
+	ChangeModes
+
+	// ChangeNames is reported when the member list of Channel has been
+	// received in full.
+	ChangeNames
+
+	// ChangeUser is reported when the username, host, account, away status
+	// or real name of Nick changes.
+	ChangeUser
+)
+
+// Change describes a change to the state tracked by State.
+type Change struct {
+	Kind ChangeKind
+
+	// Channel is the channel which changed, if any.
+	Channel string
+
+	// Nick is the nick of the user which changed, if any. For ChangeNick,
+	// it is the old nick.
+	Nick string
+
+	// NewNick is the new nick, for ChangeNick.
+	NewNick string
+
+	// Message is the message which caused the change.
+	Message *Message
+}
+
+// Channel is a channel, as tracked by State.
+type Channel struct {
+	Name string
+
+	Topic      string
+	TopicSetBy string
+	TopicSetAt time.Time
+
+	// Created is the time the channel was created, from RPL_CREATIONTIME.
+	Created time.Time
+
+	// Modes maps the channel's modes to their parameters, which are empty
+	// for modes without parameters. List modes are in Lists.
+	Modes map[byte]string
+
+	// Lists maps list modes (such as 'b' for bans) to their entries. Lists
+	// are filled by the replies to queries such as "MODE #channel +b", and
+	// are then kept up to date. A list is missing until it has been
+	// fetched, as changes to it alone do not give its entries.
+	Lists map[byte][]string
+
+	// Members are the members of the channel, sorted by nick.
+	Members []Member
+}
+
+// Member is a member of a channel.
+type Member struct {
+	Nick string
+
+	// Prefixes are the member's prefixes (such as "@" for operator), in
+	// order of rank, so the highest is first.
+	Prefixes string
+}
+
+// User is a user who shares a channel with the client, or the client
+// itself, as tracked by State.
+type User struct {
+	Nick     string
+	User     string
+	Host     string
+	Account  string
+	RealName string
+
+	Away bool
+
+	// AwayMessage is the user's away message, from away-notify or
+	// RPL_AWAY. The server does not repeat the client's own message when
+	// marking it away, so it is only known once RPL_AWAY has been received.
+	AwayMessage string
+
+	// Channels are the tracked channels the user is in, sorted.
+	Channels []string
+}
+
+// State tracks the channels the client is in, their members, and the users
+// the client shares channels with, from the messages passed to Handle. It
+// is safe for concurrent use; its query methods return copies.
+//
+// State understands extended-join, account-notify, away-notify, chghost,
+// setname, multi-prefix and userhost-in-names, which should be requested
+// using Caps for the most complete information. Nicks and channel names are
+// compared using the server's casemapping.
+//
+// Pass every message after registration to Handle, which irchandle.State
+// does as a middleware. State depends on message order, so irchandle's
+// Client must be run with Sync.
+type State struct {
+	// OnChange, if set, is called after each change. It is called
+	// synchronously, and must not call Handle.
+	OnChange func(Change)
+
+	mu       sync.RWMutex
+	is       *ISupport
+	mapping  CaseMapping
+	me       string
+	channels map[string]*stateChannel
+	users    map[string]*stateUser
+}
+
+type stateChannel struct {
+	info    Channel
+	members map[string]*stateMember
+
+	// stale holds the members from before a NAMES reply which has not
+	// yet been confirmed by it; it is nil when no reply is in progress.
+	stale map[string]bool
+
+	// listing holds the list modes whose lists are being received.
+	listing map[byte]bool
+
+	// fetched holds the list modes whose lists have been received in full,
+	// and so are kept up to date.
+	fetched map[byte]bool
+}
+
+type stateMember struct {
+	user     *stateUser
+	prefixes string
+}
+
+type stateUser struct {
+	info     User
+	channels map[string]*stateChannel
+}
+
+// NewState returns a State for a connection registered as described by w.
+// If w is nil, the default ISupport is used, and the nick is learned from
+// RPL_WELCOME.
+func NewState(w *Welcome) *State {
+	s := &State{}
+	s.Reset(w)
+	return s
+}
+
+// Reset clears all state, as is needed after reconnecting. w is treated as
+// in NewState.
+func (s *State) Reset(w *Welcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w == nil {
+		w = &Welcome{}
+	}
+
+	s.is = w.ISupport
+	if s.is == nil {
+		s.is = &ISupport{}
+	}
+
+	s.mapping = s.is.CaseMapping()
+	s.me = w.Nick
+	s.channels = make(map[string]*stateChannel)
+	s.users = make(map[string]*stateUser)
+	s.users[s.fold(s.me)] = &stateUser{
+		info:     User{Nick: s.me, Account: w.Account},
+		channels: make(map[string]*stateChannel),
+	}
+}
+
+// Channels returns the names of the channels the client is in, sorted.
+func (s *State) Channels() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.channels))
+	for _, ch := range s.channels {
+		names = append(names, ch.info.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Channel returns a channel the client is in.
+func (s *State) Channel(name string) (*Channel, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ch, ok := s.channels[s.fold(name)]
+	if !ok {
+		return nil, false
+	}
+
+	info := ch.info
+
+	info.Modes = make(map[byte]string, len(ch.info.Modes))
+	for k, v := range ch.info.Modes {
+		info.Modes[k] = v
+	}
+
+	info.Lists = make(map[byte][]string, len(ch.fetched))
+	for k := range ch.fetched {
+		info.Lists[k] = append([]string(nil), ch.info.Lists[k]...)
+	}
+
+	info.Members = make([]Member, 0, len(ch.members))
+	for _, m := range ch.members {
+		info.Members = append(info.Members, Member{Nick: m.user.info.Nick, Prefixes: m.prefixes})
+	}
+	sort.Slice(info.Members, func(i, j int) bool {
+		return info.Members[i].Nick < info.Members[j].Nick
+	})
+
+	return &info, true
+}
+
+// Member returns a member of a channel.
+func (s *State) Member(channel, nick string) (Member, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ch, ok := s.channels[s.fold(channel)]
+	if !ok {
+		return Member{}, false
+	}
+
+	m, ok := ch.members[s.fold(nick)]
+	if !ok {
+		return Member{}, false
+	}
+
+	return Member{Nick: m.user.info.Nick, Prefixes: m.prefixes}, true
+}
+
+// User returns a user who shares a channel with the client, or the client
+// itself.
+func (s *State) User(nick string) (*User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[s.fold(nick)]
+	if !ok {
+		return nil, false
+	}
+
+	info := u.info
+	info.Channels = make([]string, 0, len(u.channels))
+	for _, ch := range u.channels {
+		info.Channels = append(info.Channels, ch.info.Name)
+	}
+	sort.Strings(info.Channels)
+
+	return &info, true
+}
+
+// Me returns the client's current nick.
+func (s *State) Me() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.me
+}
+
+// Handle updates the state from a message.
+func (s *State) Handle(m *Message) {
+	s.mu.Lock()
+	changes := s.handle(m)
+	onChange := s.OnChange
+	s.mu.Unlock()
+
+	if onChange != nil {
+		for _, c := range changes {
+			onChange(c)
+		}
+	}
+}
+
+func (s *State) handle(m *Message) []Change {
+	params := m.AllParams()
+	self := m.Prefix.Name != "" && s.fold(m.Prefix.Name) == s.fold(s.me)
+
+	// Any message from a known user may reveal their username and host.
+	if u := s.users[s.fold(m.Prefix.Name)]; u != nil && m.Prefix.User != "" && m.Prefix.Host != "" {
+		u.info.User = m.Prefix.User
+		u.info.Host = m.Prefix.Host
+	}
+
+	change := func(kind ChangeKind, channel, nick string) []Change {
+		return []Change{{Kind: kind, Channel: channel, Nick: nick, Message: m}}
+	}
+
+	switch m.Command {
+	case RPL_WELCOME:
+		if len(params) > 0 {
+			s.rename(s.me, params[0])
+		}
+
+	case RPL_ISUPPORT:
+		s.is.Update(m)
+		if mapping := s.is.CaseMapping(); mapping != s.mapping {
+			s.refold(mapping)
+		}
+
+	case "JOIN":
+		if len(params) == 0 {
+			return nil
+		}
+
+		ch := s.channels[s.fold(params[0])]
+		if self {
+			if ch != nil {
+				s.removeChannel(ch)
+			}
+
+			ch = &stateChannel{
+				info: Channel{
+					Name:  params[0],
+					Modes: make(map[byte]string),
+					Lists: make(map[byte][]string),
+				},
+				members: make(map[string]*stateMember),
+				listing: make(map[byte]bool),
+				fetched: make(map[byte]bool),
+			}
+			s.channels[s.fold(params[0])] = ch
+		}
+
+		if ch == nil {
+			return nil
+		}
+
+		u := s.user(m.Prefix)
+
+		// extended-join: JOIN <channel> <account> :<realname>
+		if len(params) > 2 {
+			u.info.Account = ParseAccount(params[1])
+			u.info.RealName = params[2]
+		}
+
+		s.addMember(ch, u, "")
+		return change(ChangeJoin, ch.info.Name, u.info.Nick)
+
+	case "PART":
+		if len(params) == 0 {
+			return nil
+		}
+		return s.part(m, params[0], m.Prefix.Name)
+
+	case "KICK":
+		if len(params) < 2 {
+			return nil
+		}
+		return s.part(m, params[0], params[1])
+
+	case "QUIT":
+		u := s.users[s.fold(m.Prefix.Name)]
+		if u == nil || self {
+			return nil
+		}
+
+		for _, ch := range u.channels {
+			s.removeMember(ch, u)
+		}
+		return change(ChangeQuit, "", u.info.Nick)
+
+	case "NICK":
+		if len(params) == 0 {
+			return nil
+		}
+
+		if s.users[s.fold(m.Prefix.Name)] == nil {
+			return nil
+		}
+
+		s.rename(m.Prefix.Name, params[0])
+		return []Change{{Kind: ChangeNick, Nick: m.Prefix.Name, NewNick: params[0], Message: m}}
+
+	case "MODE":
+		if len(params) < 2 {
+			return nil
+		}
+
+		ch := s.channels[s.fold(params[0])]
+		if ch == nil {
+			return nil
+		}
+
+		s.applyModes(ch, ParseChannelModes(s.is, params[1:]))
+		return change(ChangeModes, ch.info.Name, "")
+
+	case RPL_CHANNELMODEIS:
+		// <client> <channel> <modestring> <mode arguments>...
+		if len(params) < 3 {
+			return nil
+		}
+
+		ch := s.channels[s.fold(params[1])]
+		if ch == nil {
+			return nil
+		}
+
+		ch.info.Modes = make(map[byte]string)
+		s.applyModes(ch, ParseChannelModes(s.is, params[2:]))
+		return change(ChangeModes, ch.info.Name, "")
+
+	case RPL_CREATIONTIME:
+		// <client> <channel> <creationtime>
+		if ch := s.channelParam(params, 1, 3); ch != nil {
+			ch.info.Created = unixTime(params[2])
+		}
+
+	case "TOPIC":
+		if len(params) < 2 {
+			return nil
+		}
+
+		ch := s.channels[s.fold(params[0])]
+		if ch == nil {
+			return nil
+		}
+
+		ch.info.Topic = params[1]
+		ch.info.TopicSetBy = m.Prefix.Name
+		ch.info.TopicSetAt = m.Time()
+		return change(ChangeTopic, ch.info.Name, m.Prefix.Name)
+
+	case RPL_NOTOPIC:
+		if ch := s.channelParam(params, 1, 2); ch != nil {
+			ch.info.Topic = ""
+			ch.info.TopicSetBy = ""
+			ch.info.TopicSetAt = time.Time{}
+			return change(ChangeTopic, ch.info.Name, "")
+		}
+
+	case RPL_TOPIC:
+		// <client> <channel> :<topic>
+		if ch := s.channelParam(params, 1, 3); ch != nil {
+			ch.info.Topic = params[2]
+			return change(ChangeTopic, ch.info.Name, "")
+		}
+
+	case RPL_TOPICWHOTIME:
+		// <client> <channel> <nick> <setat>
+		if ch := s.channelParam(params, 1, 4); ch != nil {
+			ch.info.TopicSetBy = ParsePrefix(params[2]).Name
+			ch.info.TopicSetAt = unixTime(params[3])
+		}
+
+	case RPL_NAMREPLY:
+		names, ok := ParseNames(m, s.is)
+		if !ok {
+			return nil
+		}
+
+		ch := s.channels[s.fold(names.Channel)]
+		if ch == nil {
+			return nil
+		}
+
+		if ch.stale == nil {
+			ch.stale = make(map[string]bool, len(ch.members))
+			for nick := range ch.members {
+				ch.stale[nick] = true
+			}
+		}
+
+		for _, member := range names.Members {
+			u := s.user(Prefix{Name: member.Nick, User: member.User, Host: member.Host})
+			s.addMember(ch, u, member.Prefixes)
+			delete(ch.stale, s.fold(member.Nick))
+		}
+
+	case RPL_ENDOFNAMES:
+		ch := s.channelParam(params, 1, 2)
+		if ch == nil {
+			return nil
+		}
+
+		for nick := range ch.stale {
+			if m := ch.members[nick]; m != nil {
+				s.removeMember(ch, m.user)
+			}
+		}
+		ch.stale = nil
+
+		return change(ChangeNames, ch.info.Name, "")
+
+	case RPL_BANLIST, RPL_INVITELIST, RPL_EXCEPTLIST:
+		// <client> <channel> <mask> [<who> <set-ts>]
+		ch := s.channelParam(params, 1, 3)
+		if ch == nil {
+			return nil
+		}
+
+		mode := listMode(m.Command)
+		if !ch.listing[mode] {
+			ch.listing[mode] = true
+			ch.info.Lists[mode] = nil
+		}
+		ch.info.Lists[mode] = append(ch.info.Lists[mode], params[2])
+
+	case RPL_ENDOFBANLIST, RPL_ENDOFINVITELIST, RPL_ENDOFEXCEPTLIST:
+		ch := s.channelParam(params, 1, 2)
+		if ch == nil {
+			return nil
+		}
+
+		mode := listMode(m.Command)
+		if !ch.listing[mode] {
+			// The list is empty.
+			ch.info.Lists[mode] = nil
+		}
+		delete(ch.listing, mode)
+		ch.fetched[mode] = true
+
+		return change(ChangeModes, ch.info.Name, "")
+
+	case "ACCOUNT":
+		if u := s.users[s.fold(m.Prefix.Name)]; u != nil && len(params) > 0 {
+			u.info.Account = ParseAccount(params[0])
+			return change(ChangeUser, "", u.info.Nick)
+		}
+
+	case "AWAY":
+		if u := s.users[s.fold(m.Prefix.Name)]; u != nil {
+			u.info.Away = len(params) > 0
+			u.info.AwayMessage = ""
+			if u.info.Away {
+				u.info.AwayMessage = params[0]
+			}
+			return change(ChangeUser, "", u.info.Nick)
+		}
+
+	case RPL_AWAY:
+		// <client> <nick> :<message>
+		if len(params) < 3 {
+			return nil
+		}
+		if u := s.users[s.fold(params[1])]; u != nil {
+			u.info.Away = true
+			u.info.AwayMessage = params[2]
+			return change(ChangeUser, "", u.info.Nick)
+		}
+
+	case RPL_UNAWAY, RPL_NOWAWAY:
+		if u := s.users[s.fold(s.me)]; u != nil {
+			u.info.Away = m.Command == RPL_NOWAWAY
+			u.info.AwayMessage = ""
+			return change(ChangeUser, "", u.info.Nick)
+		}
+
+	case "CHGHOST":
+		if u := s.users[s.fold(m.Prefix.Name)]; u != nil && len(params) > 1 {
+			u.info.User = params[0]
+			u.info.Host = params[1]
+			return change(ChangeUser, "", u.info.Nick)
+		}
+
+	case "SETNAME":
+		if u := s.users[s.fold(m.Prefix.Name)]; u != nil && len(params) > 0 {
+			u.info.RealName = params[0]
+			return change(ChangeUser, "", u.info.Nick)
+		}
+	}
+
+	return nil
+}
+
+func (s *State) fold(name string) string {
+	return s.mapping.Fold(name)
+}
+
+// refold rebuilds every map keyed by a nick or channel name after the
+// casemapping changes.
+func (s *State) refold(mapping CaseMapping) {
+	s.mapping = mapping
+
+	channels := make(map[string]*stateChannel, len(s.channels))
+	for _, ch := range s.channels {
+		channels[s.fold(ch.info.Name)] = ch
+
+		members := make(map[string]*stateMember, len(ch.members))
+		for _, m := range ch.members {
+			members[s.fold(m.user.info.Nick)] = m
+		}
+
+		if ch.stale != nil {
+			stale := make(map[string]bool, len(ch.stale))
+			for key := range ch.stale {
+				if m := ch.members[key]; m != nil {
+					stale[s.fold(m.user.info.Nick)] = true
+				}
+			}
+			ch.stale = stale
+		}
+
+		ch.members = members
+	}
+	s.channels = channels
+
+	users := make(map[string]*stateUser, len(s.users))
+	for _, u := range s.users {
+		users[s.fold(u.info.Nick)] = u
+
+		uc := make(map[string]*stateChannel, len(u.channels))
+		for _, ch := range u.channels {
+			uc[s.fold(ch.info.Name)] = ch
+		}
+		u.channels = uc
+	}
+	s.users = users
+}
+
+// channelParam returns the tracked channel named by params[i], if there are
+// at least n params.
+func (s *State) channelParam(params []string, i, n int) *stateChannel {
+	if len(params) < n {
+		return nil
+	}
+	return s.channels[s.fold(params[i])]
+}
+
+// user returns the user with the prefix's nick, adding them if needed.
+func (s *State) user(p Prefix) *stateUser {
+	key := s.fold(p.Name)
+
+	u := s.users[key]
+	if u == nil {
+		u = &stateUser{
+			info:     User{Nick: p.Name},
+			channels: make(map[string]*stateChannel),
+		}
+		s.users[key] = u
+	}
+
+	if p.User != "" {
+		u.info.User = p.User
+	}
+	if p.Host != "" {
+		u.info.Host = p.Host
+	}
+
+	return u
+}
+
+func (s *State) addMember(ch *stateChannel, u *stateUser, prefixes string) {
+	nick := s.fold(u.info.Nick)
+
+	if m := ch.members[nick]; m != nil {
+		m.prefixes = prefixes
+		return
+	}
+
+	ch.members[nick] = &stateMember{user: u, prefixes: prefixes}
+	u.channels[s.fold(ch.info.Name)] = ch
+}
+
+// removeMember removes a user from a channel, forgetting them if they no
+// longer share a channel with the client.
+func (s *State) removeMember(ch *stateChannel, u *stateUser) {
+	nick := s.fold(u.info.Nick)
+
+	delete(ch.members, nick)
+	delete(u.channels, s.fold(ch.info.Name))
+
+	if len(u.channels) == 0 && nick != s.fold(s.me) {
+		delete(s.users, nick)
+	}
+}
+
+func (s *State) removeChannel(ch *stateChannel) {
+	for _, m := range ch.members {
+		s.removeMember(ch, m.user)
+	}
+	delete(s.channels, s.fold(ch.info.Name))
+}
+
+func (s *State) part(m *Message, channel, nick string) []Change {
+	ch := s.channels[s.fold(channel)]
+	if ch == nil {
+		return nil
+	}
+
+	member := ch.members[s.fold(nick)]
+	if member == nil {
+		return nil
+	}
+
+	nick = member.user.info.Nick
+
+	if s.fold(nick) == s.fold(s.me) {
+		s.removeChannel(ch)
+	} else {
+		s.removeMember(ch, member.user)
+	}
+
+	return []Change{{Kind: ChangePart, Channel: ch.info.Name, Nick: nick, Message: m}}
+}
+
+func (s *State) rename(from, to string) {
+	oldKey, newKey := s.fold(from), s.fold(to)
+
+	if oldKey == s.fold(s.me) {
+		s.me = to
+	}
+
+	u := s.users[oldKey]
+	if u == nil {
+		return
+	}
+
+	u.info.Nick = to
+	delete(s.users, oldKey)
+	s.users[newKey] = u
+
+	for _, ch := range u.channels {
+		if m := ch.members[oldKey]; m != nil {
+			delete(ch.members, oldKey)
+			ch.members[newKey] = m
+		}
+	}
+}
+
+func (s *State) applyModes(ch *stateChannel, changes []ModeChange) {
+	a, _, _, _ := s.is.ChanModes()
+	prefixModes, prefixes := s.is.Prefix()
+
+	for _, c := range changes {
+		if i := strings.IndexByte(prefixModes, c.Mode); i != -1 {
+			m := ch.members[s.fold(c.Param)]
+			if m == nil {
+				continue
+			}
+
+			prefix := prefixes[i]
+			has := strings.IndexByte(m.prefixes, prefix) != -1
+
+			switch {
+			case c.Add && !has:
+				m.prefixes = sortPrefixes(m.prefixes+string(prefix), prefixes)
+			case !c.Add && has:
+				m.prefixes = strings.Replace(m.prefixes, string(prefix), "", 1)
+			}
+			continue
+		}
+
+		if strings.IndexByte(a, c.Mode) != -1 {
+			// Until a list has been fetched, there is nothing to keep up to
+			// date.
+			if !ch.fetched[c.Mode] && !ch.listing[c.Mode] {
+				continue
+			}
+
+			list := ch.info.Lists[c.Mode]
+
+			if c.Add {
+				ch.info.Lists[c.Mode] = append(list, c.Param)
+				continue
+			}
+
+			for i, entry := range list {
+				if entry == c.Param {
+					ch.info.Lists[c.Mode] = append(list[:i:i], list[i+1:]...)
+					break
+				}
+			}
+			continue
+		}
+
+		if c.Add {
+			ch.info.Modes[c.Mode] = c.Param
+		} else {
+			delete(ch.info.Modes, c.Mode)
+		}
+	}
+}
+
+// sortPrefixes sorts a member's prefixes by their rank in all.
+func sortPrefixes(prefixes, all string) string {
+	if len(prefixes) < 2 {
+		return prefixes
+	}
+
+	b := []byte(prefixes)
+	sort.Slice(b, func(i, j int) bool {
+		return strings.IndexByte(all, b[i]) < strings.IndexByte(all, b[j])
+	})
+
+	return string(b)
+}
+
+func listMode(command string) byte {
+	switch command {
+	case RPL_INVITELIST, RPL_ENDOFINVITELIST:
+		return 'I'
+	case RPL_EXCEPTLIST, RPL_ENDOFEXCEPTLIST:
+		return 'e'
+	default:
+		return 'b'
+	}
+}
+
+// ParseAccount returns the account named by an account-notify or
+// extended-join parameter, which is empty for "*" (not logged in).
+func ParseAccount(name string) string {
+	if name == "*" {
+		return ""
+	}
+	return name
+}
+
+func unixTime(s string) time.Time {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(n, 0)
+}
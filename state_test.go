@@ -0,0 +1,198 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestState(t *testing.T, lines ...string) (*State, []Change) {
+	is := &ISupport{}
+	m, err := ParseMessage(":irc.example.org 005 jake CASEMAPPING=rfc1459 PREFIX=(qov)~@+ CHANMODES=b,k,l,imnt :are supported")
+	assert.NoError(t, err)
+	is.Update(m)
+
+	s := NewState(&Welcome{Nick: "jake", ISupport: is})
+
+	var changes []Change
+	s.OnChange = func(c Change) {
+		changes = append(changes, c)
+	}
+
+	for _, line := range lines {
+		m, err := ParseMessage(line)
+		assert.NoError(t, err)
+		s.Handle(m)
+	}
+
+	return s, changes
+}
+
+func TestState(t *testing.T) {
+	s, changes := newTestState(t,
+		":jake!j@example.org JOIN #Chan jbailey :Jake Bailey",
+		":irc.example.org 332 jake #chan :Hello world",
+		":irc.example.org 333 jake #chan alice!a@h 1500000000",
+		":irc.example.org 353 jake = #chan :~@alice!a@a.org +bob!b@b.org jake!j@example.org",
+		":irc.example.org 366 jake #chan :End of /NAMES list.",
+		":irc.example.org 324 jake #chan +ntk secret",
+		":irc.example.org 367 jake #chan *!*@old alice 1500000000",
+		":irc.example.org 368 jake #chan :End of channel ban list",
+		":alice!a@a.org MODE #chan +vb-o bob *!*@spam alice",
+		":carol!c@c.org JOIN #chan * :Carol",
+		":bob!b@b.org NICK Bob[away]",
+		":Bob[away]!b@b.org AWAY :gone",
+		":carol!c@c.org ACCOUNT carol",
+		":carol!c@c.org CHGHOST carol new.host",
+		":carol!c@new.host SETNAME :Carol C",
+		":alice!a@a.org KICK #chan carol :bye",
+	)
+
+	assert.Equal(t, []string{"#Chan"}, s.Channels())
+
+	ch, ok := s.Channel("#CHAN")
+	assert.True(t, ok)
+	assert.Equal(t, "Hello world", ch.Topic)
+	assert.Equal(t, "alice", ch.TopicSetBy)
+	assert.Equal(t, int64(1500000000), ch.TopicSetAt.Unix())
+	assert.Equal(t, map[byte]string{'n': "", 't': "", 'k': "secret"}, ch.Modes)
+	assert.Equal(t, map[byte][]string{'b': {"*!*@old", "*!*@spam"}}, ch.Lists)
+	assert.Equal(t, []Member{
+		{Nick: "Bob[away]", Prefixes: "+"},
+		{Nick: "alice", Prefixes: "~"},
+		{Nick: "jake", Prefixes: ""},
+	}, ch.Members)
+
+	// rfc1459 casemapping folds [] to {}.
+	u, ok := s.User("bob{AWAY}")
+	assert.True(t, ok)
+	assert.Equal(t, &User{
+		Nick:        "Bob[away]",
+		User:        "b",
+		Host:        "b.org",
+		Away:        true,
+		AwayMessage: "gone",
+		Channels:    []string{"#Chan"},
+	}, u)
+
+	u, ok = s.User("jake")
+	assert.True(t, ok)
+	assert.Equal(t, "jbailey", u.Account)
+	assert.Equal(t, "Jake Bailey", u.RealName)
+
+	// carol was kicked, and shares no other channel.
+	_, ok = s.User("carol")
+	assert.False(t, ok)
+
+	var kinds []ChangeKind
+	for _, c := range changes {
+		kinds = append(kinds, c.Kind)
+	}
+	assert.Equal(t, []ChangeKind{
+		ChangeJoin, ChangeTopic, ChangeNames, ChangeModes, ChangeModes, ChangeModes, ChangeJoin,
+		ChangeNick, ChangeUser, ChangeUser, ChangeUser, ChangeUser, ChangePart,
+	}, kinds)
+}
+
+func TestStateNamesRefresh(t *testing.T) {
+	s, _ := newTestState(t,
+		":jake!j@h JOIN #chan",
+		":irc.example.org 353 jake = #chan :@jake alice bob",
+		":irc.example.org 366 jake #chan :End of /NAMES list.",
+		":irc.example.org 367 jake #chan *!*@a",
+		":irc.example.org 368 jake #chan :End of channel ban list",
+		":irc.example.org 353 jake = #chan :@jake +alice",
+		":irc.example.org 366 jake #chan :End of /NAMES list.",
+		":bob!b@b QUIT :gone",
+		":irc.example.org 368 jake #chan :End of channel ban list",
+	)
+
+	ch, _ := s.Channel("#chan")
+	assert.Equal(t, []Member{{Nick: "alice", Prefixes: "+"}, {Nick: "jake", Prefixes: "@"}}, ch.Members)
+	assert.Empty(t, ch.Lists['b'])
+
+	_, ok := s.User("bob")
+	assert.False(t, ok)
+
+	s.Handle(&Message{Prefix: Prefix{Name: "jake"}, Command: "PART", Params: []string{"#chan"}})
+	assert.Empty(t, s.Channels())
+
+	_, ok = s.User("alice")
+	assert.False(t, ok)
+}
+
+func TestStateUnfetchedLists(t *testing.T) {
+	s, _ := newTestState(t,
+		":jake!j@h JOIN #chan",
+		":alice!a@a.org MODE #chan +b *!*@spam",
+	)
+
+	// Only the new entry is known, so the list is not.
+	ch, _ := s.Channel("#chan")
+	_, ok := ch.Lists['b']
+	assert.False(t, ok)
+}
+
+func TestStateAway(t *testing.T) {
+	s, _ := newTestState(t,
+		":jake!j@h JOIN #chan",
+		":irc.example.org 306 jake :You have been marked as being away",
+		":irc.example.org 301 jake jake :lunch",
+	)
+
+	u, _ := s.User("jake")
+	assert.True(t, u.Away)
+	assert.Equal(t, "lunch", u.AwayMessage)
+
+	s.Handle(&Message{Command: RPL_UNAWAY, Params: []string{"jake"}, Trailing: "You are no longer marked as being away"})
+
+	u, _ = s.User("jake")
+	assert.False(t, u.Away)
+	assert.Empty(t, u.AwayMessage)
+}
+
+func TestStateCaseMappingChange(t *testing.T) {
+	s, _ := newTestState(t,
+		":jake!j@h JOIN #chan[1]",
+		":irc.example.org 353 jake = #chan[1] :jake Bob[1]",
+		":irc.example.org 366 jake #chan[1] :End of /NAMES list.",
+		":irc.example.org 005 jake CASEMAPPING=ascii :are supported",
+	)
+
+	// Under ascii, [] and {} are different, but case still folds.
+	_, ok := s.Channel("#chan{1}")
+	assert.False(t, ok)
+	_, ok = s.Channel("#CHAN[1]")
+	assert.True(t, ok)
+
+	_, ok = s.User("bob{1}")
+	assert.False(t, ok)
+	_, ok = s.Member("#chan[1]", "bob[1]")
+	assert.True(t, ok)
+
+	s.Handle(&Message{Prefix: Prefix{Name: "bob[1]"}, Command: "NICK", Params: []string{"bob"}})
+	u, ok := s.User("BOB")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"#chan[1]"}, u.Channels)
+}
+
+func TestStateNilWelcome(t *testing.T) {
+	s := NewState(nil)
+	s.Handle(&Message{Command: RPL_WELCOME, Params: []string{"jake"}, Trailing: "Welcome"})
+	s.Handle(&Message{Prefix: Prefix{Name: "JAKE"}, Command: "JOIN", Params: []string{"#chan"}})
+
+	assert.Equal(t, "jake", s.Me())
+	assert.Equal(t, []string{"#chan"}, s.Channels())
+}
+
+func TestParseChannelModes(t *testing.T) {
+	is := &ISupport{}
+
+	assert.Equal(t, []ModeChange{
+		{Add: true, Mode: 'o', Param: "jake"},
+		{Add: true, Mode: 'l', Param: "10"},
+		{Add: false, Mode: 'l'},
+		{Add: false, Mode: 'k', Param: "key"},
+		{Add: false, Mode: 'm'},
+	}, ParseChannelModes(is, []string{"+ol-lkm", "jake", "10", "key"}))
+}
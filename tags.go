@@ -0,0 +1,101 @@
+package irc
+
+import (
+	"strings"
+	"time"
+)
+
+// ServerTimeFormat is the format of the "time" tag, used by server-time.
+const ServerTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// TagKey is a message tag key, which may be prefixed with "+" for a
+// client-only tag, and may contain a vendor, as in "+example.com/tag".
+type TagKey string
+
+// ClientOnly returns true if the tag is client-only (prefixed with "+"),
+// meaning it was sent by another client rather than the server.
+func (k TagKey) ClientOnly() bool {
+	return strings.HasPrefix(string(k), "+")
+}
+
+// Vendor returns the vendor of the tag, which is the part before the "/",
+// or the empty string if the tag is not vendor-specific.
+func (k TagKey) Vendor() string {
+	s := strings.TrimPrefix(string(k), "+")
+	if i := strings.IndexByte(s, '/'); i != -1 {
+		return s[:i]
+	}
+	return ""
+}
+
+// Name returns the tag's name, without the client-only prefix or vendor.
+func (k TagKey) Name() string {
+	s := strings.TrimPrefix(string(k), "+")
+	if i := strings.IndexByte(s, '/'); i != -1 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// Tag returns the value of a tag, and whether it is present.
+func (m *Message) Tag(key TagKey) (value string, ok bool) {
+	value, ok = m.Tags[string(key)]
+	return value, ok
+}
+
+// ServerTime returns the time from the "time" tag (from server-time),
+// and whether it was present and valid.
+func (m *Message) ServerTime() (time.Time, bool) {
+	v, ok := m.Tags["time"]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	// RFC3339Nano also accepts the usual millisecond precision.
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// Time returns the time the message was sent according to the server, or
+// if that is unknown, the time it was received.
+func (m *Message) Time() time.Time {
+	if t, ok := m.ServerTime(); ok {
+		return t
+	}
+	return m.Received
+}
+
+// MsgID returns the message's ID, from the "msgid" tag.
+func (m *Message) MsgID() string {
+	return m.Tags["msgid"]
+}
+
+// Account returns the account of the message's sender, from the "account"
+// tag (from account-tag), or the empty string if they are not logged in.
+func (m *Message) Account() string {
+	return m.Tags["account"]
+}
+
+// Label returns the label of a labeled response.
+func (m *Message) Label() string {
+	return m.Tags["label"]
+}
+
+// Batch returns the reference of the batch the message belongs to.
+func (m *Message) Batch() string {
+	return m.Tags["batch"]
+}
+
+// IsBot returns true if the message has the "bot" tag, which servers set
+// on messages from clients marked as bots.
+func (m *Message) IsBot() bool {
+	_, ok := m.Tags["bot"]
+	if !ok {
+		_, ok = m.Tags["draft/bot"]
+	}
+	return ok
+}
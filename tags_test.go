@@ -0,0 +1,55 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagKey(t *testing.T) {
+	tests := []struct {
+		key        TagKey
+		clientOnly bool
+		vendor     string
+		name       string
+	}{
+		{"time", false, "", "time"},
+		{"+typing", true, "", "typing"},
+		{"+draft/reply", true, "draft", "reply"},
+		{"example.com/foo", false, "example.com", "foo"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.clientOnly, test.key.ClientOnly(), test.key)
+		assert.Equal(t, test.vendor, test.key.Vendor(), test.key)
+		assert.Equal(t, test.name, test.key.Name(), test.key)
+	}
+}
+
+func TestMessageTags(t *testing.T) {
+	m, err := ParseMessage(`@time=2019-02-28T19:30:01.727Z;msgid=abc;account=jake;bot;+draft/reply=xyz :jake!j@h PRIVMSG #chan :hi`)
+	assert.NoError(t, err)
+
+	want := time.Date(2019, 2, 28, 19, 30, 1, 727000000, time.UTC)
+	assert.True(t, want.Equal(m.Time()))
+	assert.Equal(t, "2019-02-28T19:30:01.727Z", m.Time().Format(ServerTimeFormat))
+
+	assert.Equal(t, "abc", m.MsgID())
+	assert.Equal(t, "jake", m.Account())
+	assert.True(t, m.IsBot())
+
+	v, ok := m.Tag("+draft/reply")
+	assert.True(t, ok)
+	assert.Equal(t, "xyz", v)
+
+	m, err = ParseMessage(`@time=bogus PRIVMSG #chan :hi`)
+	assert.NoError(t, err)
+	m.Received = time.Unix(1234, 0)
+
+	_, ok = m.ServerTime()
+	assert.False(t, ok)
+	assert.Equal(t, m.Received, m.Time())
+	assert.False(t, m.IsBot())
+	assert.Empty(t, m.Account())
+}